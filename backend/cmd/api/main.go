@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -11,6 +14,7 @@ import (
 	"github.com/DHRUVV23/ai-code-review/backend/internal/database"
 	"github.com/DHRUVV23/ai-code-review/backend/internal/handler"
 	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
 	"github.com/DHRUVV23/ai-code-review/backend/internal/worker"
 )
 
@@ -31,26 +35,76 @@ func main() {
 	redisOpt := asynq.RedisClientOpt{Addr: cfg.RedisAddr}
 	asynqClient := asynq.NewClient(redisOpt)
 	defer asynqClient.Close()
+	asynqInspector := asynq.NewInspector(redisOpt)
+	defer asynqInspector.Close()
 
 	worker.StartWorker(cfg.RedisAddr)
 
+	secrets, err := service.NewSecretBoxFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid SECRET_ENCRYPTION_KEY: %v", err)
+	}
+	githubEndpoints, err := service.DefaultGitHubEndpoints()
+	if err != nil {
+		log.Fatalf("Invalid GitHub endpoints: %v", err)
+	}
+	if githubEndpoints.Enterprise() {
+		log.Printf("Using GitHub Enterprise Server at %s", githubEndpoints.APIURL)
+	}
+	if app, err := service.DefaultGitHubApp(); err != nil {
+		log.Fatalf("Invalid GitHub App configuration: %v", err)
+	} else if app != nil {
+		log.Printf("Running as GitHub App %d", app.AppID)
+		if os.Getenv("GITHUB_APP_WEBHOOK_SECRET") == "" {
+			log.Println("⚠️ GITHUB_APP_WEBHOOK_SECRET not set, app deliveries are only accepted for repositories without their own secret")
+		}
+	}
+	if secrets == nil {
+		log.Println("⚠️ SECRET_ENCRYPTION_KEY not set, all repositories share GITHUB_WEBHOOK_SECRET")
+	}
+
 	userRepo := repository.NewUserRepository(database.Pool)
 	repoRepo := repository.NewRepoRepository(database.Pool)
 	configRepo := repository.NewConfigRepository(database.Pool)
+	policyRepo := repository.NewPolicyRepository(database.Pool)
+	deliveryRepo := repository.NewDeliveryRepository(database.Pool)
+	installationRepo := repository.NewInstallationRepository(database.Pool)
+	go pruneWebhookPayloads(deliveryRepo, cfg.PayloadRetention)
 
 	authHandler := &handler.AuthHandler{
 		UserRepo: userRepo,
 		Config:   cfg,
+		GitHub:   githubEndpoints,
 	}
 
 	repoHandler := &handler.RepoHandler{
 		RepoRepository:   repoRepo,
 		ConfigRepository: configRepo,
 		UserRepository:   userRepo,
+		Secrets:          secrets,
 	}
 
 	webhookHandler := &handler.WebhookHandler{
-		Client: asynqClient,
+		Client:             asynqClient,
+		Inspector:          asynqInspector,
+		DeliveryRepository: deliveryRepo,
+		RepoRepository:     repoRepo,
+		ConfigRepository:   configRepo,
+		Secrets:            secrets,
+
+		InstallationRepository: installationRepo,
+		UserRepository:         userRepo,
+	}
+
+	autofixHandler := &handler.AutofixHandler{
+		RepoRepository: repoRepo,
+		Client:         asynqClient,
+	}
+
+	policyHandler := &handler.PolicyHandler{
+		RepoRepository:   repoRepo,
+		PolicyRepository: policyRepo,
+		UserRepository:   userRepo,
 	}
 
 	r := gin.Default()
@@ -67,6 +121,8 @@ func main() {
 		c.JSON(200, gin.H{"message": "pong"})
 	})
 	r.POST("/webhook", webhookHandler.HandleWebhook) 
+	r.POST("/webhook/gitlab", webhookHandler.HandleGitLabWebhook)
+	r.POST("/webhook/gitea", webhookHandler.HandleGiteaWebhook)
 	r.GET("/auth/github/login", authHandler.GitHubLogin)
 	r.GET("/auth/github/callback", authHandler.GitHubCallback)
 
@@ -82,10 +138,42 @@ func main() {
 		v1.PUT("/repositories/:id/config", repoHandler.UpdateConfig)
 		
 		v1.POST("/repositories/:id/webhook", repoHandler.CreateWebhook)
+		v1.POST("/repositories/:id/webhook/rotate-secret", repoHandler.RotateWebhookSecret)
+		v1.POST("/repositories/:id/pulls/:number/autofix", autofixHandler.RequestAutofix)
+
+		v1.GET("/repositories/:id/data-policy", policyHandler.GetRepoPolicy)
+		v1.PUT("/repositories/:id/data-policy", policyHandler.UpdateRepoPolicy)
+		v1.GET("/orgs/:owner/data-policy", policyHandler.GetOrgPolicy)
+		v1.PUT("/orgs/:owner/data-policy", policyHandler.UpdateOrgPolicy)
+	}
+
+	admin := r.Group("/admin", handler.RequireAdminToken(cfg.AdminToken))
+	{
+		admin.GET("/webhook-deliveries", webhookHandler.ListDeliveries)
+		admin.GET("/webhook-deliveries/:id", webhookHandler.GetDelivery)
+		admin.POST("/webhook-deliveries/:id/replay", webhookHandler.ReplayDelivery)
 	}
 
 	log.Println("🚀 Server running on :8080")
 	if err := r.Run(":8080"); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+// pruneWebhookPayloads drops stored webhook payloads once they are older than
+// the retention period, and expired dedup claims. The delivery records
+// themselves are kept.
+func pruneWebhookPayloads(repo *repository.DeliveryRepository, retention time.Duration) {
+	for {
+		pruned, err := repo.PrunePayloads(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("⚠️ %v", err)
+		} else if pruned > 0 {
+			log.Printf("Pruned %d expired webhook payload(s)", pruned)
+		}
+		if _, err := repo.PurgeExpiredClaims(context.Background()); err != nil {
+			log.Printf("⚠️ %v", err)
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -14,6 +14,7 @@ CREATE TABLE IF NOT EXISTS installations (
     account_id BIGINT NOT NULL,
     account_type VARCHAR(50) NOT NULL, -- 'User' or 'Organization'
     account_login VARCHAR(255) NOT NULL,
+    suspended BOOLEAN DEFAULT FALSE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
@@ -22,17 +23,26 @@ CREATE TABLE IF NOT EXISTS repositories (
     id SERIAL PRIMARY KEY,
     github_repo_id BIGINT UNIQUE NOT NULL,
     installation_id INT REFERENCES installations(id) ON DELETE CASCADE,
+    added_by_installation BOOLEAN DEFAULT FALSE, -- registered by the app, deleted on uninstall; others are only unlinked
     name VARCHAR(255) NOT NULL,
     full_name VARCHAR(255) NOT NULL, -- e.g. "octocat/hello-world"
     private BOOLEAN DEFAULT FALSE,
+    provider VARCHAR(20) DEFAULT 'github', -- 'github', 'gitlab' or 'gitea'
+    base_url TEXT, -- Self-hosted instance; required for gitea
+    webhook_id BIGINT,
+    webhook_secret TEXT, -- AES-GCM sealed with SECRET_ENCRYPTION_KEY
+    previous_webhook_secret TEXT, -- still accepted until previous_secret_expires_at
+    previous_secret_expires_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
--- 4. Reviews (One record per Pull Request scan)
+-- 4. Reviews (One record per Pull Request or pushed commit range scan)
 CREATE TABLE IF NOT EXISTS reviews (
     id SERIAL PRIMARY KEY,
     repository_id INT REFERENCES repositories(id) ON DELETE CASCADE,
-    pr_number INT NOT NULL,
+    target_type VARCHAR(20) DEFAULT 'pull_request', -- 'pull_request' or 'commit_range'
+    pr_number INT, -- set for pull_request reviews
+    commit_range VARCHAR(100), -- "before..after", set for commit_range reviews
     commit_sha VARCHAR(40) NOT NULL,
     status VARCHAR(50) NOT NULL, -- 'pending', 'completed', 'failed'
     issues_found INT DEFAULT 0,
@@ -52,5 +62,77 @@ CREATE TABLE IF NOT EXISTS review_issues (
     title VARCHAR(255) NOT NULL,
     message TEXT NOT NULL,
     suggestion TEXT,
+    start_line INT DEFAULT 0, -- replacement range for suggested_code
+    end_line INT DEFAULT 0,
+    suggested_code TEXT,
+    source VARCHAR(20) DEFAULT 'ai', -- 'ai', 'static', 'secrets' or 'prompt-guard'
+    github_comment_id BIGINT DEFAULT 0, -- root of the inline review thread
+    false_positive BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- 6. Review Threads (Developer <-> bot conversation under an inline finding)
+CREATE TABLE IF NOT EXISTS review_threads (
+    id SERIAL PRIMARY KEY,
+    issue_id INT REFERENCES review_issues(id) ON DELETE CASCADE,
+    github_comment_id BIGINT UNIQUE NOT NULL,
+    author VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    from_bot BOOLEAN DEFAULT FALSE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-);
\ No newline at end of file
+);
+
+-- 7. Data Policies (Paths that must never reach an external model)
+CREATE TABLE IF NOT EXISTS data_policies (
+    id SERIAL PRIMARY KEY,
+    owner VARCHAR(255) NOT NULL, -- org or user login
+    repository_id INT NOT NULL DEFAULT 0, -- 0 = applies to every repo of the owner
+    never_send TEXT, -- globs, one per line
+    local_only TEXT, -- globs only sent to self-hosted models
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (owner, repository_id)
+);
+
+-- 8. PR Outcomes (Final state of merged PRs, for analytics)
+CREATE TABLE IF NOT EXISTS pr_outcomes (
+    id SERIAL PRIMARY KEY,
+    repository_id INT REFERENCES repositories(id) ON DELETE CASCADE,
+    pr_number INT NOT NULL,
+    merged_at TIMESTAMP WITH TIME ZONE,
+    merge_commit_sha VARCHAR(40),
+    head_sha VARCHAR(40),
+    additions INT DEFAULT 0,
+    deletions INT DEFAULT 0,
+    changed_files INT DEFAULT 0,
+    reviews INT DEFAULT 0, -- reviews the bot ran on the PR
+    findings INT DEFAULT 0,
+    false_positives INT DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (repository_id, pr_number)
+);
+
+-- 9. Webhook Deliveries (Every webhook received, for debugging and replay)
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    delivery_id VARCHAR(64), -- X-GitHub-Delivery
+    event VARCHAR(64),
+    action VARCHAR(64),
+    repository VARCHAR(255), -- owner/name
+    signature_valid BOOLEAN DEFAULT FALSE,
+    status_code INT DEFAULT 0,
+    outcome TEXT,
+    payload TEXT, -- cleared after WEBHOOK_PAYLOAD_RETENTION_DAYS
+    replay_of INT REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+    received_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_received ON webhook_deliveries (received_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_delivery ON webhook_deliveries (delivery_id);
+
+-- 10. Webhook Dedup (One claim per X-GitHub-Delivery across pods, until it expires)
+CREATE TABLE IF NOT EXISTS webhook_dedup (
+    delivery_id VARCHAR(64) PRIMARY KEY,
+    claimed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -3,6 +3,8 @@ package config
 import (
 	// "log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -13,6 +15,8 @@ type Config struct {
 	GithubClientID     string
 	GithubClientSecret string
 	WebhookSecret      string
+	AdminToken         string        // X-Admin-Token for the /admin routes
+	PayloadRetention   time.Duration // How long webhook payloads are kept for replay
 }
 
 func LoadConfig() (*Config, error) {
@@ -28,6 +32,12 @@ func LoadConfig() (*Config, error) {
 		GithubClientID:     os.Getenv("GITHUB_CLIENT_ID"),
 		GithubClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
 		WebhookSecret:      os.Getenv("GITHUB_WEBHOOK_SECRET"),
+		AdminToken:         os.Getenv("ADMIN_TOKEN"),
+		PayloadRetention:   7 * 24 * time.Hour,
+	}
+
+	if days, err := strconv.Atoi(os.Getenv("WEBHOOK_PAYLOAD_RETENTION_DAYS")); err == nil && days > 0 {
+		cfg.PayloadRetention = time.Duration(days) * 24 * time.Hour
 	}
 
 	return cfg, nil
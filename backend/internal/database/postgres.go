@@ -92,11 +92,154 @@ func InitDB() error {
 		return err
 	}
 
+	// E. Review Issues (one row per finding, linked to its inline comment)
+	if _, err := Pool.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS review_issues (
+			id SERIAL PRIMARY KEY,
+			review_id INT NOT NULL,
+			file_path TEXT NOT NULL,
+			line_number INT NOT NULL,
+			severity TEXT NOT NULL,
+			category TEXT NOT NULL,
+			message TEXT NOT NULL,
+			suggestion TEXT,
+			github_comment_id BIGINT DEFAULT 0,
+			false_positive BOOLEAN DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT NOW(),
+			CONSTRAINT fk_review FOREIGN KEY(review_id) REFERENCES reviews(id) ON DELETE CASCADE
+		);`); err != nil {
+		return err
+	}
+
+	// F. Review Threads (conversation under each inline finding)
+	if _, err := Pool.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS review_threads (
+			id SERIAL PRIMARY KEY,
+			issue_id INT NOT NULL,
+			github_comment_id BIGINT UNIQUE NOT NULL,
+			author TEXT NOT NULL,
+			body TEXT NOT NULL,
+			from_bot BOOLEAN DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT NOW(),
+			CONSTRAINT fk_issue FOREIGN KEY(issue_id) REFERENCES review_issues(id) ON DELETE CASCADE
+		);`); err != nil {
+		return err
+	}
+
+	// G. Data Policies (paths that must not be sent to a model; repository_id 0 = whole org)
+	if _, err := Pool.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS data_policies (
+			id SERIAL PRIMARY KEY,
+			owner TEXT NOT NULL,
+			repository_id INT NOT NULL DEFAULT 0,
+			never_send TEXT,
+			local_only TEXT,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW(),
+			UNIQUE (owner, repository_id)
+		);`); err != nil {
+		return err
+	}
+
+	// H. PR Outcomes (final state of merged PRs, for analytics)
+	if _, err := Pool.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS pr_outcomes (
+			id SERIAL PRIMARY KEY,
+			repository_id INT NOT NULL,
+			pr_number INT NOT NULL,
+			merged_at TIMESTAMP,
+			merge_commit_sha TEXT,
+			head_sha TEXT,
+			additions INT DEFAULT 0,
+			deletions INT DEFAULT 0,
+			changed_files INT DEFAULT 0,
+			reviews INT DEFAULT 0,
+			findings INT DEFAULT 0,
+			false_positives INT DEFAULT 0,
+			created_at TIMESTAMP DEFAULT NOW(),
+			UNIQUE (repository_id, pr_number),
+			CONSTRAINT fk_repo_outcome FOREIGN KEY(repository_id) REFERENCES repositories(id) ON DELETE CASCADE
+		);`); err != nil {
+		return err
+	}
+
+	// I. Webhook Deliveries (what GitHub sent us and what we did with it)
+	if _, err := Pool.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id SERIAL PRIMARY KEY,
+			delivery_id TEXT,
+			event TEXT,
+			action TEXT,
+			repository TEXT,
+			signature_valid BOOLEAN DEFAULT FALSE,
+			status_code INT DEFAULT 0,
+			outcome TEXT,
+			payload TEXT,
+			replay_of INT REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+			received_at TIMESTAMP DEFAULT NOW()
+		);`); err != nil {
+		return err
+	}
+
+	// J. Webhook Dedup (delivery IDs already claimed by a pod, until they expire)
+	if _, err := Pool.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS webhook_dedup (
+			delivery_id TEXT PRIMARY KEY,
+			claimed_at TIMESTAMP DEFAULT NOW(),
+			expires_at TIMESTAMP NOT NULL
+		);`); err != nil {
+		return err
+	}
+
+	// K. Installations (GitHub App installations on user and org accounts)
+	if _, err := Pool.Exec(context.Background(), `
+		CREATE TABLE IF NOT EXISTS installations (
+			id SERIAL PRIMARY KEY,
+			github_installation_id BIGINT UNIQUE NOT NULL,
+			account_login TEXT NOT NULL,
+			account_type TEXT,
+			suspended BOOLEAN DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT NOW()
+		);`); err != nil {
+		return err
+	}
+
 	// 3. SMART MIGRATION: Add columns individually if they are missing
 	migrations := []string{
 		"ALTER TABLE reviews ADD COLUMN IF NOT EXISTS content TEXT;",
 		"ALTER TABLE reviews ADD COLUMN IF NOT EXISTS commit_sha TEXT;",
 		"ALTER TABLE reviews ADD COLUMN IF NOT EXISTS status TEXT;", 
+		"CREATE INDEX IF NOT EXISTS idx_review_issues_comment ON review_issues (github_comment_id);",
+		"ALTER TABLE configurations ADD COLUMN IF NOT EXISTS summary_mode TEXT DEFAULT 'comment';",
+		"ALTER TABLE review_issues ADD COLUMN IF NOT EXISTS start_line INT DEFAULT 0;",
+		"ALTER TABLE review_issues ADD COLUMN IF NOT EXISTS end_line INT DEFAULT 0;",
+		"ALTER TABLE review_issues ADD COLUMN IF NOT EXISTS suggested_code TEXT;",
+		"ALTER TABLE review_issues ADD COLUMN IF NOT EXISTS source TEXT DEFAULT 'ai';",
+		"ALTER TABLE configurations ADD COLUMN IF NOT EXISTS secret_patterns TEXT;",
+		"ALTER TABLE configurations ADD COLUMN IF NOT EXISTS secret_allowlist TEXT;",
+		"ALTER TABLE configurations ADD COLUMN IF NOT EXISTS secret_scan_blocking BOOLEAN DEFAULT FALSE;",
+		"ALTER TABLE configurations ADD COLUMN IF NOT EXISTS sensitive_patterns TEXT;",
+		"ALTER TABLE configurations ADD COLUMN IF NOT EXISTS license_allowlist TEXT;",
+		"ALTER TABLE configurations ADD COLUMN IF NOT EXISTS generated_patterns TEXT;",
+		"ALTER TABLE configurations ADD COLUMN IF NOT EXISTS hotspot_patterns TEXT;",
+		"ALTER TABLE configurations ADD COLUMN IF NOT EXISTS labels JSONB;",
+		"ALTER TABLE configurations ADD COLUMN IF NOT EXISTS ownership_rules TEXT;",
+		"ALTER TABLE configurations ADD COLUMN IF NOT EXISTS review_drafts BOOLEAN DEFAULT FALSE;",
+		"ALTER TABLE repositories ADD COLUMN IF NOT EXISTS webhook_id BIGINT;",
+		"ALTER TABLE repositories ADD COLUMN IF NOT EXISTS webhook_secret TEXT;",
+		"ALTER TABLE repositories ADD COLUMN IF NOT EXISTS previous_webhook_secret TEXT;",
+		"ALTER TABLE repositories ADD COLUMN IF NOT EXISTS previous_secret_expires_at TIMESTAMP;",
+		"ALTER TABLE repositories ADD COLUMN IF NOT EXISTS installation_id BIGINT;",
+		"ALTER TABLE repositories ADD COLUMN IF NOT EXISTS added_by_installation BOOLEAN DEFAULT FALSE;", // Created by the app, removed on uninstall
+		"ALTER TABLE repositories ALTER COLUMN user_id DROP NOT NULL;", // App installs can come from users who never logged in
+		"ALTER TABLE repositories ADD COLUMN IF NOT EXISTS provider TEXT DEFAULT 'github';",
+		"ALTER TABLE repositories ADD COLUMN IF NOT EXISTS base_url TEXT;",
+		"ALTER TABLE reviews ADD COLUMN IF NOT EXISTS target_type TEXT DEFAULT 'pull_request';",
+		"ALTER TABLE reviews ADD COLUMN IF NOT EXISTS commit_range TEXT;",
+		"ALTER TABLE reviews ALTER COLUMN pr_number DROP NOT NULL;", // Push reviews have no PR
+		"ALTER TABLE configurations ADD COLUMN IF NOT EXISTS push_branches TEXT;",
+		"CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_received ON webhook_deliveries (received_at);",
+		"CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_delivery ON webhook_deliveries (delivery_id);",
 	}
 
 	for _, query := range migrations {
@@ -9,16 +9,17 @@ import (
 	// Using your preferred import structure
 	"github.com/DHRUVV23/ai-code-review/backend/internal/config"
 	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
 	
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/github"
 )
 
 type AuthHandler struct {
 	UserRepo *repository.UserRepository
 	Config   *config.Config // <--- Added this missing field
+	GitHub   service.GitHubEndpoints // github.com, or the Enterprise Server to log in with
 }
 
 // GitHubLogin redirects the user to GitHub
@@ -28,7 +29,7 @@ func (h *AuthHandler) GitHubLogin(c *gin.Context) {
 		ClientID:     h.Config.GithubClientID,
 		ClientSecret: h.Config.GithubClientSecret,
 		RedirectURL:  "http://localhost:8080/auth/github/callback",
-		Endpoint:     github.Endpoint,
+		Endpoint:     h.GitHub.OAuthEndpoint(),
 		Scopes:       []string{"user:email", "read:user", "repo", "admin:repo_hook"},
 	}
 	// AccessTypeOffline asks for a refresh token (optional), ApprovalForce forces the screen
@@ -44,7 +45,7 @@ func (h *AuthHandler) GitHubCallback(c *gin.Context) {
 		ClientID:     h.Config.GithubClientID,
 		ClientSecret: h.Config.GithubClientSecret,
 		RedirectURL:  "http://localhost:8080/auth/github/callback",
-		Endpoint:     github.Endpoint,
+		Endpoint:     h.GitHub.OAuthEndpoint(),
 	}
 
 	token, err := conf.Exchange(context.Background(), code)
@@ -54,7 +55,7 @@ func (h *AuthHandler) GitHubCallback(c *gin.Context) {
 	}
 
 	client := conf.Client(context.Background(), token)
-	resp, err := client.Get("https://api.github.com/user")
+	resp, err := client.Get(h.GitHub.UserURL())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user info"})
 		return
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/config"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newEnterpriseAuthHandler points the login flow at a fake Enterprise Server
+// serving the OAuth exchange and the user profile
+func newEnterpriseAuthHandler(t *testing.T) (*AuthHandler, *httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var profileReads atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "code" {
+			http.Error(w, "bad code", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"gho_user","token_type":"bearer"}`))
+	})
+	mux.HandleFunc("/api/v3/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_user" {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		profileReads.Add(1)
+		w.Write([]byte(`{"id":1,"login":"octocat"}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	endpoints, err := service.NewGitHubEndpoints(server.URL+"/api", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nothing listens on the database port, so the callback stops at saving the user
+	pool, err := pgxpool.New(context.Background(), "postgres://test@127.0.0.1:1/test?connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	return &AuthHandler{
+		UserRepo: repository.NewUserRepository(pool),
+		Config:   &config.Config{GithubClientID: "id", GithubClientSecret: "secret"},
+		GitHub:   endpoints,
+	}, server, &profileReads
+}
+
+func TestGitHubLoginEnterprise(t *testing.T) {
+	h, server, _ := newEnterpriseAuthHandler(t)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/auth/github/login", nil)
+	h.GitHubLogin(c)
+
+	if location := w.Header().Get("Location"); !strings.HasPrefix(location, server.URL+"/login/oauth/authorize?") {
+		t.Errorf("login redirects to %q, want the Enterprise Server", location)
+	}
+}
+
+func TestGitHubCallbackEnterprise(t *testing.T) {
+	h, _, profileReads := newEnterpriseAuthHandler(t)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/auth/github/callback?code=code", nil)
+	h.GitHubCallback(c)
+
+	if profileReads.Load() != 1 {
+		t.Fatalf("profile read %d times from the Enterprise Server, want 1 (response %d: %s)", profileReads.Load(), w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "Failed to save user") {
+		t.Errorf("callback response = %d %s, want it to reach saving the user", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v50/github"
+	"github.com/hibiken/asynq"
+)
+
+type AutofixHandler struct {
+	RepoRepository *repository.RepoRepository
+	Client         *asynq.Client
+}
+
+// RequestAutofix - Handles POST /api/v1/repositories/:id/pulls/:number/autofix
+// It does the same as commenting "/ai-fix" on the PR.
+func (h *AutofixHandler) RequestAutofix(c *gin.Context) {
+	userID := getUserIDFromToken(c)
+	if userID == 0 {
+		return
+	}
+
+	repoID, _ := strconv.Atoi(c.Param("id"))
+	prNumber, err := strconv.Atoi(c.Param("number"))
+	if err != nil || prNumber <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid PR number"})
+		return
+	}
+
+	repo, err := h.RepoRepository.GetRepositoryByID(c.Request.Context(), repoID)
+	if err != nil || repo == nil || repo.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return
+	}
+
+	task, err := worker.NewAutofixTask(worker.AutofixPayload{
+		RepoName:  repo.Name,
+		RepoOwner: repo.Owner,
+		PRNumber:  prNumber,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Error"})
+		return
+	}
+
+	// One request per PR per minute is plenty; repeated clicks collapse into one task
+	taskID := fmt.Sprintf("autofix:%s/%s:%d:%d", repo.Owner, repo.Name, prNumber, time.Now().Unix()/60)
+	if _, err := h.Client.Enqueue(task, asynq.TaskID(taskID), asynq.Retention(1*time.Hour)); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			c.JSON(http.StatusAccepted, gin.H{"status": "already_queued"})
+			return
+		}
+		log.Printf(" Failed to enqueue autofix task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "queued"})
+}
+
+// authorizeAutofix reports whether the commenter may have the bot push a
+// branch and open a PR with its credentials: the repository owner or anyone
+// with write access. Org members and collaborators may only have read or
+// triage access, so they are looked up. Anyone else gets a refusal on the PR.
+func authorizeAutofix(ctx context.Context, e *github.IssueCommentEvent) (bool, error) {
+	if e.GetComment().GetAuthorAssociation() == "OWNER" {
+		return true, nil
+	}
+
+	repo := e.GetRepo()
+	owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+	user := e.GetComment().GetUser().GetLogin()
+	ghService, err := service.NewGitHubServiceFor(ctx, owner, name, e.GetInstallation().GetID())
+	if err != nil {
+		return false, err
+	}
+	allowed, err := ghService.CanPush(ctx, owner, name, user)
+	if err != nil || allowed {
+		return allowed, err
+	}
+
+	body := fmt.Sprintf("@%s only people with write access to this repository can use `/ai-fix`.\n\n%s", user, service.BotCommentMarker)
+	if err := ghService.PostComment(ctx, owner, name, e.GetIssue().GetNumber(), body); err != nil {
+		log.Printf(" Failed to refuse /ai-fix: %v", err)
+	}
+	return false, nil
+}
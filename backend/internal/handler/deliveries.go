@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// maxListedDeliveries caps GET /admin/webhook-deliveries
+const maxListedDeliveries = 200
+
+// DeliveryDedupTTL is how long a processed delivery ID is remembered. GitHub
+// only lets deliveries be redelivered for 3 days.
+const DeliveryDedupTTL = 72 * time.Hour
+
+// DeliveryClaimTTL bounds a claim while its delivery is processed, so that a
+// crash mid-request doesn't block redeliveries for DeliveryDedupTTL
+const DeliveryClaimTTL = 5 * time.Minute
+
+// RequireAdminToken guards the admin routes with the X-Admin-Token header.
+// Without a configured token the admin routes are disabled.
+func RequireAdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		given := c.GetHeader("X-Admin-Token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// responseRecorder keeps a copy of the response so the delivery log can say
+// what happened to the event
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// processAndRecord runs a verified delivery through processEvent and logs
+// the result
+func (h *WebhookHandler) processAndRecord(c *gin.Context, delivery *model.WebhookDelivery) {
+	var meta struct {
+		Action     string `json:"action"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+
+		// GitLab
+		ObjectAttributes struct {
+			Action string `json:"action"`
+		} `json:"object_attributes"`
+		Project struct {
+			PathWithNamespace string `json:"path_with_namespace"`
+		} `json:"project"`
+	}
+	_ = json.Unmarshal([]byte(delivery.Payload), &meta)
+	delivery.Action = meta.Action
+	delivery.Repository = meta.Repository.FullName
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	if isGitLabEvent(delivery.Event) {
+		delivery.Action = meta.ObjectAttributes.Action
+		delivery.Repository = meta.Project.PathWithNamespace
+		h.processGitLabEvent(c, []byte(delivery.Payload))
+	} else if isGiteaEvent(delivery.Event) {
+		h.processGiteaEvent(c, delivery.Event, []byte(delivery.Payload))
+	} else {
+		h.processEvent(c, delivery.Event, []byte(delivery.Payload))
+	}
+	c.Writer = recorder.ResponseWriter
+
+	delivery.StatusCode = recorder.Status()
+	var response map[string]string
+	if json.Unmarshal(recorder.body.Bytes(), &response) == nil {
+		for _, key := range []string{"status", "error", "message"} {
+			if response[key] != "" {
+				delivery.Outcome = response[key]
+				break
+			}
+		}
+	}
+	h.recordDelivery(c, delivery)
+}
+
+// claimDelivery reports whether this request should process the delivery.
+// Without a delivery ID or a database to dedup against, it always should.
+func (h *WebhookHandler) claimDelivery(c *gin.Context, deliveryID string) bool {
+	if h.DeliveryRepository == nil || deliveryID == "" {
+		return true
+	}
+	claimed, err := h.DeliveryRepository.ClaimDelivery(c.Request.Context(), deliveryID, DeliveryClaimTTL)
+	if err != nil {
+		// Task IDs still catch most duplicates, so don't drop the event
+		log.Printf(" Delivery dedup unavailable: %v", err)
+		return true
+	}
+	return claimed
+}
+
+// settleDelivery keeps the claim of a processed delivery for
+// DeliveryDedupTTL, and drops it when processing failed so that the
+// provider's retry is processed again
+func (h *WebhookHandler) settleDelivery(c *gin.Context, delivery *model.WebhookDelivery) {
+	if h.DeliveryRepository == nil || delivery.DeliveryID == "" {
+		return
+	}
+	ctx := c.Request.Context()
+	if delivery.StatusCode >= http.StatusInternalServerError {
+		if err := h.DeliveryRepository.ReleaseDelivery(ctx, delivery.DeliveryID); err != nil {
+			log.Printf(" Failed to release delivery %s: %v", delivery.DeliveryID, err)
+		}
+		return
+	}
+	if err := h.DeliveryRepository.ExtendClaim(ctx, delivery.DeliveryID, DeliveryDedupTTL); err != nil {
+		log.Printf(" Failed to keep delivery %s: %v", delivery.DeliveryID, err)
+	}
+}
+
+func (h *WebhookHandler) recordDelivery(c *gin.Context, delivery *model.WebhookDelivery) {
+	if h.DeliveryRepository == nil {
+		return
+	}
+	if err := h.DeliveryRepository.RecordDelivery(c.Request.Context(), delivery); err != nil {
+		log.Printf(" Failed to record delivery %s: %v", delivery.DeliveryID, err)
+	}
+}
+
+// ListDeliveries - Handles GET /admin/webhook-deliveries
+// Optional filters: ?repository=owner/name&event=pull_request&limit=50
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	if limit > maxListedDeliveries {
+		limit = maxListedDeliveries
+	}
+
+	deliveries, err := h.DeliveryRepository.ListDeliveries(c.Request.Context(), c.Query("repository"), c.Query("event"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list deliveries"})
+		return
+	}
+	if deliveries == nil {
+		deliveries = []model.WebhookDelivery{}
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetDelivery - Handles GET /admin/webhook-deliveries/:id
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	delivery := h.findDelivery(c)
+	if delivery == nil {
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// ReplayDelivery - Handles POST /admin/webhook-deliveries/:id/replay
+// The stored payload goes through the same processing as a live delivery.
+// Its signature was checked when it was received.
+func (h *WebhookHandler) ReplayDelivery(c *gin.Context) {
+	original := h.findDelivery(c)
+	if original == nil {
+		return
+	}
+	if !original.SignatureValid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Delivery failed signature validation and cannot be replayed"})
+		return
+	}
+	if original.Payload == "" {
+		c.JSON(http.StatusGone, gin.H{"error": "Payload is no longer retained"})
+		return
+	}
+
+	log.Printf(" Replaying delivery %s (%s)", original.DeliveryID, original.Event)
+	h.processAndRecord(c, &model.WebhookDelivery{
+		DeliveryID:     original.DeliveryID,
+		Event:          original.Event,
+		SignatureValid: true,
+		Payload:        original.Payload,
+		ReplayOf:       &original.ID,
+	})
+}
+
+func (h *WebhookHandler) findDelivery(c *gin.Context) *model.WebhookDelivery {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return nil
+	}
+	delivery, err := h.DeliveryRepository.GetDelivery(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load delivery"})
+		return nil
+	}
+	if delivery == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return nil
+	}
+	return delivery
+}
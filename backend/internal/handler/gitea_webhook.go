@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
+)
+
+// giteaEventPrefix keeps Gitea event names ("pull_request") apart from
+// GitHub's in the delivery log
+const giteaEventPrefix = "gitea:"
+
+// giteaPullRequestEvent is the part of a Gitea/Forgejo pull request event we use
+type giteaPullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Title  string `json:"title"`
+		Merged bool   `json:"merged"`
+		Head   struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			SHA string `json:"sha"`
+		} `json:"base"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		Name     string `json:"name"`
+		HTMLURL  string `json:"html_url"`
+		Owner    struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+func isGiteaEvent(event string) bool {
+	return strings.HasPrefix(event, giteaEventPrefix)
+}
+
+// HandleGiteaWebhook - Handles POST /webhook/gitea
+// Gitea and Forgejo sign the body with HMAC-SHA256 and send the hex digest
+// in X-Gitea-Signature (Forgejo also in X-Forgejo-Signature).
+func (h *WebhookHandler) HandleGiteaWebhook(c *gin.Context) {
+	delivery := &model.WebhookDelivery{
+		DeliveryID: c.GetHeader("X-Gitea-Delivery"),
+		Event:      giteaEventPrefix + c.GetHeader("X-Gitea-Event"),
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read webhook"})
+		return
+	}
+
+	var meta giteaPullRequestEvent
+	_ = json.Unmarshal(body, &meta)
+
+	signature := c.GetHeader("X-Gitea-Signature")
+	if signature == "" {
+		signature = c.GetHeader("X-Forgejo-Signature")
+	}
+	if !h.validGiteaSignature(c.Request.Context(), signature, body, meta.Repository.Owner.Login, meta.Repository.Name) {
+		log.Printf("Invalid Gitea signature for %s", meta.Repository.FullName)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		delivery.StatusCode = http.StatusUnauthorized
+		delivery.Outcome = "invalid signature"
+		h.recordDelivery(c, delivery)
+		return
+	}
+
+	delivery.SignatureValid = true
+	delivery.Payload = string(body)
+
+	if !h.claimDelivery(c, delivery.DeliveryID) {
+		log.Printf(" Duplicate delivery ignored: %s", delivery.DeliveryID)
+		c.JSON(http.StatusOK, gin.H{"status": "duplicate_delivery"})
+		delivery.StatusCode = http.StatusOK
+		delivery.Outcome = "duplicate_delivery"
+		h.recordDelivery(c, delivery)
+		return
+	}
+
+	h.processAndRecord(c, delivery)
+	h.settleDelivery(c, delivery)
+}
+
+// validGiteaSignature checks the signature against the repository's own
+// secrets, or GITEA_WEBHOOK_SECRET for repositories without one
+func (h *WebhookHandler) validGiteaSignature(ctx context.Context, signature string, body []byte, owner, name string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+
+	fallback := []byte(os.Getenv("GITEA_WEBHOOK_SECRET"))
+	candidates := [][]byte{fallback}
+	if h.RepoRepository != nil && name != "" {
+		candidates = h.repoSecrets(ctx, service.ProviderGitea, owner, name, fallback)
+	}
+
+	for _, secret := range candidates {
+		if len(secret) == 0 {
+			continue
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		if hmac.Equal(got, mac.Sum(nil)) {
+			return true
+		}
+	}
+	return false
+}
+
+// processGiteaEvent queues reviews for pull requests and cancels the work of
+// closed ones. Gitea sends synchronize and label changes as their own event
+// types, so any pull_request* event is looked at by action.
+func (h *WebhookHandler) processGiteaEvent(c *gin.Context, event string, payload []byte) {
+	if !strings.HasPrefix(strings.TrimPrefix(event, giteaEventPrefix), "pull_request") {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	var e giteaPullRequestEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		log.Printf("Could not parse Gitea webhook: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not parse webhook"})
+		return
+	}
+
+	owner, name := e.Repository.Owner.Login, e.Repository.Name
+	pr := e.PullRequest
+
+	if e.Action == "closed" {
+		if h.Inspector != nil {
+			if cancelled, err := worker.CancelPRTasks(h.Inspector, service.ProviderGitea, owner, name, e.Number); err != nil {
+				log.Printf(" Failed to cancel tasks of PR #%d: %v", e.Number, err)
+			} else if cancelled > 0 {
+				log.Printf(" Cancelled %d task(s) of closed PR #%d", cancelled, e.Number)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
+		return
+	}
+
+	// Gitea doesn't say which label changed, so the force label counts
+	// whenever labels are updated while it is set
+	force := false
+	switch e.Action {
+	case "opened", "reopened", "synchronized":
+	case "label_updated":
+		if !hasGiteaLabel(e, LabelForceReview) {
+			c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+			return
+		}
+		force = true
+	default:
+		log.Printf("Ignoring PR action: %s", e.Action)
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	if hasGiteaLabel(e, LabelSkipReview) {
+		log.Printf(" Skipping PR #%d: labeled %s", e.Number, LabelSkipReview)
+		c.JSON(http.StatusOK, gin.H{"status": "skipped"})
+		return
+	}
+
+	title := strings.ToUpper(pr.Title)
+	task, err := worker.NewReviewTask(worker.ReviewPayload{
+		RepoName:  name,
+		RepoOwner: owner,
+		PRNumber:  e.Number,
+		HeadSHA:   pr.Head.SHA,
+		BaseSHA:   pr.Base.SHA,
+		Draft:     strings.HasPrefix(title, "WIP:") || strings.HasPrefix(title, "[WIP]"),
+		Force:     force,
+		Provider:  service.ProviderGitea,
+		BaseURL:   h.registeredBaseURL(c.Request.Context(), service.ProviderGitea, owner, name, e.Repository.HTMLURL),
+	})
+	if err != nil {
+		log.Printf("Failed to create task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Error"})
+		return
+	}
+
+	taskID := fmt.Sprintf("review:gitea:%s:%d:%s", e.Repository.FullName, e.Number, pr.Head.SHA)
+	if force {
+		taskID += ":forced"
+	}
+	if _, err := h.Client.Enqueue(task, asynq.TaskID(taskID), asynq.Retention(1*time.Hour)); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			log.Printf(" Duplicate Review Task Ignored: %s", taskID)
+			c.JSON(http.StatusOK, gin.H{"status": "duplicate_ignored"})
+			return
+		}
+		log.Printf(" Failed to enqueue task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
+		return
+	}
+
+	log.Printf(" Review Job Enqueued for PR #%d of %s", e.Number, e.Repository.FullName)
+	c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
+}
+
+func hasGiteaLabel(e giteaPullRequestEvent, name string) bool {
+	for _, l := range e.PullRequest.Labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func giteaSignature(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidGiteaSignature(t *testing.T) {
+	h := &WebhookHandler{}
+	body := []byte(`{"action":"opened"}`)
+
+	t.Setenv("GITEA_WEBHOOK_SECRET", "s3cret")
+	if !h.validGiteaSignature(t.Context(), giteaSignature("s3cret", string(body)), body, "octo", "hello") {
+		t.Error("valid signature rejected")
+	}
+	if h.validGiteaSignature(t.Context(), giteaSignature("other", string(body)), body, "octo", "hello") {
+		t.Error("signature made with another secret accepted")
+	}
+	if h.validGiteaSignature(t.Context(), giteaSignature("s3cret", `{"action":"closed"}`), body, "octo", "hello") {
+		t.Error("signature of another body accepted")
+	}
+	if h.validGiteaSignature(t.Context(), "not hex", body, "octo", "hello") {
+		t.Error("malformed signature accepted")
+	}
+	if h.validGiteaSignature(t.Context(), "", body, "octo", "hello") {
+		t.Error("missing signature accepted")
+	}
+
+	// Without a secret there is nothing to check against, so nothing passes
+	t.Setenv("GITEA_WEBHOOK_SECRET", "")
+	if h.validGiteaSignature(t.Context(), giteaSignature("", string(body)), body, "octo", "hello") {
+		t.Error("signature accepted with no secret configured")
+	}
+}
+
+func TestHandleGiteaWebhookSignatureHeaders(t *testing.T) {
+	t.Setenv("GITEA_WEBHOOK_SECRET", "s3cret")
+	// A push is ignored once verified, so nothing beyond the check runs
+	body := `{"repository":{"full_name":"octo/hello","name":"hello","owner":{"login":"octo"}}}`
+
+	tests := []struct {
+		name   string
+		header string
+		secret string
+		want   int
+	}{
+		{"gitea", "X-Gitea-Signature", "s3cret", http.StatusOK},
+		{"forgejo", "X-Forgejo-Signature", "s3cret", http.StatusOK},
+		{"wrong secret", "X-Forgejo-Signature", "other", http.StatusUnauthorized},
+		{"unsigned", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/webhook/gitea", strings.NewReader(body))
+			c.Request.Header.Set("X-Gitea-Event", "push")
+			if tt.header != "" {
+				c.Request.Header.Set(tt.header, giteaSignature(tt.secret, body))
+			}
+
+			(&WebhookHandler{}).HandleGiteaWebhook(c)
+			if w.Code != tt.want {
+				t.Errorf("status = %d (%s), want %d", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
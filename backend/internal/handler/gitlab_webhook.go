@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
+)
+
+type gitlabLabel struct {
+	Title string `json:"title"`
+}
+
+// gitlabMergeRequestEvent is the part of a "Merge Request Hook" we use
+type gitlabMergeRequestEvent struct {
+	ObjectKind string `json:"object_kind"`
+	Project    struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		WebURL            string `json:"web_url"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		IID            int    `json:"iid"`
+		Action         string `json:"action"`
+		Draft          bool   `json:"draft"`
+		WorkInProgress bool   `json:"work_in_progress"`
+		OldRev         string `json:"oldrev"` // Only set when an update pushed commits
+		LastCommit     struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+	Labels  []gitlabLabel `json:"labels"`
+	Changes struct {
+		Labels *struct {
+			Previous []gitlabLabel `json:"previous"`
+			Current  []gitlabLabel `json:"current"`
+		} `json:"labels"`
+	} `json:"changes"`
+}
+
+// isGitLabEvent tells X-Gitlab-Event values ("Merge Request Hook") apart
+// from GitHub event names
+func isGitLabEvent(event string) bool {
+	return strings.HasSuffix(event, " Hook")
+}
+
+// splitProjectPath splits "group/subgroup/project" into namespace and project
+func splitProjectPath(path string) (owner, name string) {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return "", path
+	}
+	return path[:i], path[i+1:]
+}
+
+// registeredBaseURL returns the base URL of the instance owner/name was
+// registered on, so the worker talks to the instance the event came from
+func (h *WebhookHandler) registeredBaseURL(ctx context.Context, provider, owner, name, webURL string) string {
+	if h.RepoRepository == nil {
+		return ""
+	}
+	repo, err := h.RepoRepository.FindRepository(ctx, provider, owner, name, webURL)
+	if err != nil {
+		log.Printf(" Failed to look up %s repository %s/%s: %v", provider, owner, name, err)
+		return ""
+	}
+	if repo == nil {
+		return ""
+	}
+	return repo.BaseURL
+}
+
+// HandleGitLabWebhook - Handles POST /webhook/gitlab
+// GitLab sends the configured secret as is in X-Gitlab-Token.
+func (h *WebhookHandler) HandleGitLabWebhook(c *gin.Context) {
+	delivery := &model.WebhookDelivery{
+		DeliveryID: c.GetHeader("X-Gitlab-Event-UUID"),
+		Event:      c.GetHeader("X-Gitlab-Event"),
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read webhook"})
+		return
+	}
+
+	var meta gitlabMergeRequestEvent
+	_ = json.Unmarshal(body, &meta)
+	owner, name := splitProjectPath(meta.Project.PathWithNamespace)
+
+	if !h.validGitLabToken(c.Request.Context(), c.GetHeader("X-Gitlab-Token"), owner, name) {
+		log.Printf("Invalid GitLab token for %s", meta.Project.PathWithNamespace)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		delivery.StatusCode = http.StatusUnauthorized
+		delivery.Outcome = "invalid token"
+		h.recordDelivery(c, delivery)
+		return
+	}
+
+	delivery.SignatureValid = true
+	delivery.Payload = string(body)
+
+	if !h.claimDelivery(c, delivery.DeliveryID) {
+		log.Printf(" Duplicate delivery ignored: %s", delivery.DeliveryID)
+		c.JSON(http.StatusOK, gin.H{"status": "duplicate_delivery"})
+		delivery.StatusCode = http.StatusOK
+		delivery.Outcome = "duplicate_delivery"
+		h.recordDelivery(c, delivery)
+		return
+	}
+
+	h.processAndRecord(c, delivery)
+	h.settleDelivery(c, delivery)
+}
+
+// validGitLabToken checks the token against the project's own secrets, or
+// GITLAB_WEBHOOK_TOKEN for projects without one
+func (h *WebhookHandler) validGitLabToken(ctx context.Context, token, owner, name string) bool {
+	fallback := []byte(os.Getenv("GITLAB_WEBHOOK_TOKEN"))
+	candidates := [][]byte{fallback}
+	if h.RepoRepository != nil && name != "" {
+		candidates = h.repoSecrets(ctx, service.ProviderGitLab, owner, name, fallback)
+	}
+
+	for _, secret := range candidates {
+		if len(secret) > 0 && subtle.ConstantTimeCompare([]byte(token), secret) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// processGitLabEvent queues reviews for merge requests and cancels the work
+// of closed ones
+func (h *WebhookHandler) processGitLabEvent(c *gin.Context, payload []byte) {
+	var e gitlabMergeRequestEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		log.Printf("Could not parse GitLab webhook: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not parse webhook"})
+		return
+	}
+	if e.ObjectKind != "merge_request" {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	mr := e.ObjectAttributes
+	owner, name := splitProjectPath(e.Project.PathWithNamespace)
+
+	if mr.Action == "close" || mr.Action == "merge" {
+		if h.Inspector != nil {
+			if cancelled, err := worker.CancelPRTasks(h.Inspector, service.ProviderGitLab, owner, name, mr.IID); err != nil {
+				log.Printf(" Failed to cancel tasks of MR !%d: %v", mr.IID, err)
+			} else if cancelled > 0 {
+				log.Printf(" Cancelled %d task(s) of closed MR !%d", cancelled, mr.IID)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
+		return
+	}
+
+	force := false
+	if l := e.Changes.Labels; l != nil {
+		force = hasGitLabLabel(l.Current, LabelForceReview) && !hasGitLabLabel(l.Previous, LabelForceReview)
+	}
+	switch {
+	case mr.Action == "open" || mr.Action == "reopen":
+	case mr.Action == "update" && (mr.OldRev != "" || force):
+	default:
+		log.Printf("Ignoring MR action: %s", mr.Action)
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	if hasGitLabLabel(e.Labels, LabelSkipReview) {
+		log.Printf(" Skipping MR !%d: labeled %s", mr.IID, LabelSkipReview)
+		c.JSON(http.StatusOK, gin.H{"status": "skipped"})
+		return
+	}
+
+	headSHA := mr.LastCommit.ID
+	task, err := worker.NewReviewTask(worker.ReviewPayload{
+		RepoName:  name,
+		RepoOwner: owner,
+		PRNumber:  mr.IID,
+		HeadSHA:   headSHA,
+		Draft:     mr.Draft || mr.WorkInProgress,
+		Force:     force,
+		Provider:  service.ProviderGitLab,
+		BaseURL:   h.registeredBaseURL(c.Request.Context(), service.ProviderGitLab, owner, name, e.Project.WebURL),
+	})
+	if err != nil {
+		log.Printf("Failed to create task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Error"})
+		return
+	}
+
+	taskID := fmt.Sprintf("review:gitlab:%s:%d:%s", e.Project.PathWithNamespace, mr.IID, headSHA)
+	if force {
+		taskID += ":forced"
+	}
+	if _, err := h.Client.Enqueue(task, asynq.TaskID(taskID), asynq.Retention(1*time.Hour)); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			log.Printf(" Duplicate Review Task Ignored: %s", taskID)
+			c.JSON(http.StatusOK, gin.H{"status": "duplicate_ignored"})
+			return
+		}
+		log.Printf(" Failed to enqueue task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
+		return
+	}
+
+	log.Printf(" Review Job Enqueued for MR !%d of %s", mr.IID, e.Project.PathWithNamespace)
+	c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
+}
+
+func hasGitLabLabel(labels []gitlabLabel, title string) bool {
+	for _, l := range labels {
+		if l.Title == title {
+			return true
+		}
+	}
+	return false
+}
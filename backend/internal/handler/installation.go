@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v50/github"
+)
+
+// handleInstallationEvent keeps installations and their repositories in sync
+// when the GitHub App is installed, removed or suspended
+func (h *WebhookHandler) handleInstallationEvent(c *gin.Context, e *github.InstallationEvent) {
+	ctx := c.Request.Context()
+	installation := e.GetInstallation()
+	installationID := installation.GetID()
+
+	if h.InstallationRepository == nil || h.RepoRepository == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	switch e.GetAction() {
+	case "created":
+		err := h.InstallationRepository.UpsertInstallation(ctx, &model.Installation{
+			GithubInstallationID: installationID,
+			AccountLogin:         installation.GetAccount().GetLogin(),
+			AccountType:          installation.GetAccount().GetType(),
+		})
+		if err != nil {
+			log.Printf(" Failed to save installation %d: %v", installationID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save installation"})
+			return
+		}
+		if !h.registerInstalledRepositories(ctx, installationID, e.GetSender(), e.Repositories) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register repositories"})
+			return
+		}
+		log.Printf(" App installed on %s (%d repositories)", installation.GetAccount().GetLogin(), len(e.Repositories))
+
+	case "deleted":
+		if err := h.InstallationRepository.DeleteInstallation(ctx, installationID); err != nil {
+			log.Printf(" Failed to delete installation %d: %v", installationID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete installation"})
+			return
+		}
+		if app, _ := service.DefaultGitHubApp(); app != nil {
+			app.ForgetInstallation(installationID)
+		}
+		log.Printf(" App uninstalled from %s", installation.GetAccount().GetLogin())
+
+	case "suspend", "unsuspend":
+		if err := h.InstallationRepository.SetSuspended(ctx, installationID, e.GetAction() == "suspend"); err != nil {
+			log.Printf(" Failed to update installation %d: %v", installationID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update installation"})
+			return
+		}
+
+	default:
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
+}
+
+// handleInstallationRepositoriesEvent follows repositories being added to or
+// removed from an existing installation
+func (h *WebhookHandler) handleInstallationRepositoriesEvent(c *gin.Context, e *github.InstallationRepositoriesEvent) {
+	ctx := c.Request.Context()
+	installationID := e.GetInstallation().GetID()
+
+	if h.RepoRepository == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	if !h.registerInstalledRepositories(ctx, installationID, e.GetSender(), e.RepositoriesAdded) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register repositories"})
+		return
+	}
+
+	for _, repo := range e.RepositoriesRemoved {
+		owner, name, _ := strings.Cut(repo.GetFullName(), "/")
+		if err := h.RepoRepository.RemoveInstalledRepository(ctx, installationID, owner, name); err != nil {
+			log.Printf(" Failed to remove %s: %v", repo.GetFullName(), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove repositories"})
+			return
+		}
+		log.Printf(" Repository %s removed from the app", repo.GetFullName())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
+}
+
+// registerInstalledRepositories registers repos under the user who installed
+// the app, when they have an account with us
+func (h *WebhookHandler) registerInstalledRepositories(ctx context.Context, installationID int64, sender *github.User, repos []*github.Repository) bool {
+	userID := 0
+	if h.UserRepository != nil {
+		id, err := h.UserRepository.GetUserIDByGithubID(ctx, sender.GetID())
+		if err != nil {
+			log.Printf(" Failed to look up installer %s: %v", sender.GetLogin(), err)
+		}
+		userID = id
+	}
+
+	for _, repo := range repos {
+		owner, name, _ := strings.Cut(repo.GetFullName(), "/")
+		if err := h.RepoRepository.RegisterInstalledRepository(ctx, installationID, userID, owner, name); err != nil {
+			log.Printf(" Failed to register %s: %v", repo.GetFullName(), err)
+			return false
+		}
+		log.Printf(" Repository %s registered through the app", repo.GetFullName())
+	}
+	return true
+}
+
+// installationSuspended reports whether the event came through a suspended
+// installation. Installation events always get through, or the app could
+// never be unsuspended.
+func (h *WebhookHandler) installationSuspended(ctx context.Context, event interface{}) bool {
+	switch event.(type) {
+	case *github.InstallationEvent, *github.InstallationRepositoriesEvent:
+		return false
+	}
+	e, ok := event.(interface{ GetInstallation() *github.Installation })
+	if !ok || h.InstallationRepository == nil || e.GetInstallation().GetID() == 0 {
+		return false
+	}
+
+	suspended, err := h.InstallationRepository.IsSuspended(ctx, e.GetInstallation().GetID())
+	if err != nil {
+		log.Printf(" %v", err)
+		return false
+	}
+	return suspended
+}
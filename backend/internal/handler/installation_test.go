@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/database"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testOwner          = "installation-test-owner"
+	testInstallationID = 990001
+	testGithubUserID   = 990002
+)
+
+// newInstallationTestHandler connects to the Postgres database named by
+// TEST_DB_NAME (with the usual DB_* settings) and removes the test rows
+// before and after the test
+func newInstallationTestHandler(t *testing.T) *WebhookHandler {
+	t.Helper()
+	name := os.Getenv("TEST_DB_NAME")
+	if name == "" {
+		t.Skip("TEST_DB_NAME not set, skipping database test")
+	}
+	t.Setenv("DB_NAME", name)
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+
+	cleanup := func() {
+		ctx := context.Background()
+		for _, query := range []string{
+			`DELETE FROM configurations WHERE repository_id IN (SELECT id FROM repositories WHERE owner = $1)`,
+			`DELETE FROM repositories WHERE owner = $1`,
+		} {
+			if _, err := database.Pool.Exec(ctx, query, testOwner); err != nil {
+				t.Fatalf("cleanup: %v", err)
+			}
+		}
+		database.Pool.Exec(ctx, `DELETE FROM installations WHERE github_installation_id = $1`, testInstallationID)
+		database.Pool.Exec(ctx, `DELETE FROM users WHERE github_id = $1`, testGithubUserID)
+	}
+	cleanup()
+	t.Cleanup(func() {
+		cleanup()
+		database.CloseDB()
+	})
+
+	return &WebhookHandler{
+		RepoRepository:         repository.NewRepoRepository(database.Pool),
+		InstallationRepository: repository.NewInstallationRepository(database.Pool),
+		UserRepository:         repository.NewUserRepository(database.Pool),
+	}
+}
+
+// sendEvent runs a webhook payload through processEvent
+func sendEvent(t *testing.T, h *WebhookHandler, eventType string, payload interface{}) map[string]string {
+	t.Helper()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	h.processEvent(c, eventType, body)
+
+	var response map[string]string
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s event: status %d: %v", eventType, w.Code, response)
+	}
+	return response
+}
+
+type testRepo struct {
+	ID         int64
+	Installed  *int64
+	AddedByApp bool
+	UserID     *int
+	Configured bool
+	Provider   string
+}
+
+func loadTestRepos(t *testing.T, name string) []testRepo {
+	t.Helper()
+	rows, err := database.Pool.Query(context.Background(), `
+		SELECT r.id, r.installation_id, COALESCE(r.added_by_installation, FALSE), r.user_id,
+			EXISTS (SELECT 1 FROM configurations c WHERE c.repository_id = r.id), COALESCE(r.provider, 'github')
+		FROM repositories r WHERE owner = $1 AND name = $2 ORDER BY id`, testOwner, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var repos []testRepo
+	for rows.Next() {
+		var r testRepo
+		if err := rows.Scan(&r.ID, &r.Installed, &r.AddedByApp, &r.UserID, &r.Configured, &r.Provider); err != nil {
+			t.Fatal(err)
+		}
+		repos = append(repos, r)
+	}
+	return repos
+}
+
+func installationEvent(action string, repos ...string) map[string]interface{} {
+	var list []map[string]string
+	for _, name := range repos {
+		list = append(list, map[string]string{"name": name, "full_name": testOwner + "/" + name})
+	}
+	return map[string]interface{}{
+		"action":       action,
+		"installation": map[string]interface{}{"id": testInstallationID, "account": map[string]interface{}{"login": testOwner, "type": "Organization"}},
+		"repositories": list,
+		"sender":       map[string]interface{}{"id": testGithubUserID, "login": "installer"},
+	}
+}
+
+func TestInstallationLifecycle(t *testing.T) {
+	h := newInstallationTestHandler(t)
+	ctx := context.Background()
+
+	// "manual" was registered by a user through the dashboard before the app came
+	userID, err := h.UserRepository.UpsertUser(ctx, testGithubUserID, "installer", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.RepoRepository.CreateRepository(ctx, userID, "manual", testOwner, "github", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	sendEvent(t, h, "installation", installationEvent("created", "app", "manual"))
+
+	app := loadTestRepos(t, "app")
+	if len(app) != 1 || app[0].Installed == nil || *app[0].Installed != testInstallationID || !app[0].AddedByApp || !app[0].Configured {
+		t.Fatalf("app-registered repository = %+v, want one linked, configured row created by the app", app)
+	}
+	if app[0].UserID == nil || *app[0].UserID != userID {
+		t.Errorf("app-registered repository belongs to %v, want the installer %d", app[0].UserID, userID)
+	}
+	manual := loadTestRepos(t, "manual")
+	if len(manual) != 1 || manual[0].Installed != nil || manual[0].AddedByApp {
+		t.Fatalf("manual registration = %+v, want it untouched and not duplicated", manual)
+	}
+
+	t.Run("suspend", func(t *testing.T) {
+		sendEvent(t, h, "installation", installationEvent("suspend"))
+		if suspended, err := h.InstallationRepository.IsSuspended(ctx, testInstallationID); err != nil || !suspended {
+			t.Fatalf("IsSuspended = %v, %v after suspend", suspended, err)
+		}
+
+		pr := map[string]interface{}{
+			"action":       "opened",
+			"number":       1,
+			"repository":   map[string]interface{}{"name": "app", "full_name": testOwner + "/app", "owner": map[string]string{"login": testOwner}},
+			"installation": map[string]interface{}{"id": testInstallationID},
+		}
+		if got := sendEvent(t, h, "pull_request", pr)["status"]; got != "installation_suspended" {
+			t.Errorf("pull_request on a suspended installation: status %q, want installation_suspended", got)
+		}
+
+		sendEvent(t, h, "installation", installationEvent("unsuspend"))
+		if suspended, _ := h.InstallationRepository.IsSuspended(ctx, testInstallationID); suspended {
+			t.Error("installation still suspended after unsuspend")
+		}
+	})
+
+	t.Run("repositories added and removed", func(t *testing.T) {
+		event := installationEvent("added")
+		event["repositories_added"] = []map[string]string{{"name": "added", "full_name": testOwner + "/added"}}
+		event["repositories_removed"] = []map[string]string{
+			{"name": "app", "full_name": testOwner + "/app"},
+			{"name": "manual", "full_name": testOwner + "/manual"},
+		}
+		sendEvent(t, h, "installation_repositories", event)
+
+		if added := loadTestRepos(t, "added"); len(added) != 1 || !added[0].AddedByApp {
+			t.Errorf("added repository = %+v, want it registered by the app", added)
+		}
+		if removed := loadTestRepos(t, "app"); len(removed) != 0 {
+			t.Errorf("removed repository still registered: %+v", removed)
+		}
+		if manual := loadTestRepos(t, "manual"); len(manual) != 1 {
+			t.Errorf("removing the app from a repository deleted the user's registration")
+		}
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		// A user's registration linked to the installation is unlinked, not deleted
+		if _, err := database.Pool.Exec(ctx, `UPDATE repositories SET installation_id = $1 WHERE owner = $2 AND name = 'manual'`, testInstallationID, testOwner); err != nil {
+			t.Fatal(err)
+		}
+
+		sendEvent(t, h, "installation", installationEvent("deleted"))
+
+		if added := loadTestRepos(t, "added"); len(added) != 0 {
+			t.Errorf("app-registered repository survived the uninstall: %+v", added)
+		}
+		manual := loadTestRepos(t, "manual")
+		if len(manual) != 1 || manual[0].Installed != nil || !manual[0].Configured {
+			t.Errorf("manual registration after uninstall = %+v, want it kept and unlinked", manual)
+		}
+		var count int
+		database.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM installations WHERE github_installation_id = $1`, testInstallationID).Scan(&count)
+		if count != 0 {
+			t.Error("installation row survived the uninstall")
+		}
+	})
+}
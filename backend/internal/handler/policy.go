@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v50/github"
+	"golang.org/x/oauth2"
+)
+
+type PolicyHandler struct {
+	RepoRepository   *repository.RepoRepository
+	PolicyRepository *repository.PolicyRepository
+	UserRepository   *repository.UserRepository
+}
+
+type PolicyRequest struct {
+	NeverSend string `json:"never_send"`
+	LocalOnly string `json:"local_only"`
+}
+
+// GetRepoPolicy - Handles GET /api/v1/repositories/:id/data-policy
+func (h *PolicyHandler) GetRepoPolicy(c *gin.Context) {
+	repo := h.ownedRepository(c)
+	if repo == nil {
+		return
+	}
+	h.getPolicy(c, repo.Owner, repo.ID)
+}
+
+// UpdateRepoPolicy - Handles PUT /api/v1/repositories/:id/data-policy
+func (h *PolicyHandler) UpdateRepoPolicy(c *gin.Context) {
+	repo := h.ownedRepository(c)
+	if repo == nil {
+		return
+	}
+	h.savePolicy(c, repo.Owner, repo.ID)
+}
+
+// GetOrgPolicy - Handles GET /api/v1/orgs/:owner/data-policy
+func (h *PolicyHandler) GetOrgPolicy(c *gin.Context) {
+	if !h.administersOwner(c, c.Param("owner")) {
+		return
+	}
+	h.getPolicy(c, c.Param("owner"), 0)
+}
+
+// UpdateOrgPolicy - Handles PUT /api/v1/orgs/:owner/data-policy
+// The policy applies to every repository of the organization.
+func (h *PolicyHandler) UpdateOrgPolicy(c *gin.Context) {
+	if !h.administersOwner(c, c.Param("owner")) {
+		return
+	}
+	h.savePolicy(c, c.Param("owner"), 0)
+}
+
+func (h *PolicyHandler) getPolicy(c *gin.Context, owner string, repoID int) {
+	policy, err := h.PolicyRepository.GetPolicy(c.Request.Context(), owner, repoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch data policy"})
+		return
+	}
+	if policy == nil {
+		policy = &model.DataPolicy{Owner: owner, RepositoryID: repoID}
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+func (h *PolicyHandler) savePolicy(c *gin.Context, owner string, repoID int) {
+	var req PolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if _, err := service.NewDataPolicy(req.NeverSend, req.LocalOnly); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := &model.DataPolicy{Owner: owner, RepositoryID: repoID, NeverSend: req.NeverSend, LocalOnly: req.LocalOnly}
+	if err := h.PolicyRepository.UpsertPolicy(c.Request.Context(), policy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save data policy"})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+func (h *PolicyHandler) ownedRepository(c *gin.Context) *model.Repository {
+	userID := getUserIDFromToken(c)
+	if userID == 0 {
+		return nil
+	}
+
+	repoID, _ := strconv.Atoi(c.Param("id"))
+	repo, err := h.RepoRepository.GetRepositoryByID(c.Request.Context(), repoID)
+	if err != nil || repo == nil || repo.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return nil
+	}
+	return repo
+}
+
+// administersOwner only lets the account itself, or admins of the
+// organization, manage its policy
+func (h *PolicyHandler) administersOwner(c *gin.Context, owner string) bool {
+	userID := getUserIDFromToken(c)
+	if userID == 0 {
+		return false
+	}
+
+	user, err := h.UserRepository.GetUserByID(c.Request.Context(), userID)
+	if err != nil || user.AccessToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "GitHub token not found. Please logout and login again."})
+		return false
+	}
+	if strings.EqualFold(user.Username, owner) {
+		return true
+	}
+
+	ctx := c.Request.Context()
+	client := service.NewGitHubClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: user.AccessToken})))
+	membership, _, err := client.Organizations.GetOrgMembership(ctx, "", owner)
+	if err != nil {
+		var ghErr *github.ErrorResponse
+		if errors.As(err, &ghErr) && ghErr.Response != nil &&
+			(ghErr.Response.StatusCode == http.StatusNotFound || ghErr.Response.StatusCode == http.StatusForbidden) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return false
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to check organization membership"})
+		return false
+	}
+	if membership.GetState() != "active" || membership.GetRole() != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only organization admins can manage its data policy"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v50/github"
+	"github.com/hibiken/asynq"
+)
+
+// zeroSHA is the "before" of a push that created the branch
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// handlePushEvent queues a review of the commits pushed to a branch listed in
+// the repository's push_branches. New and deleted branches have no range to review.
+func (h *WebhookHandler) handlePushEvent(c *gin.Context, e *github.PushEvent) {
+	branch, ok := strings.CutPrefix(e.GetRef(), "refs/heads/")
+	if !ok || e.GetDeleted() || e.GetCreated() || e.GetBefore() == zeroSHA || e.GetBefore() == e.GetAfter() {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+	if h.RepoRepository == nil || h.ConfigRepository == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	repoOwner := e.GetRepo().GetOwner().GetLogin()
+	if repoOwner == "" {
+		repoOwner = e.GetRepo().GetOwner().GetName()
+	}
+	repoName := e.GetRepo().GetName()
+	ctx := c.Request.Context()
+
+	repo, err := h.RepoRepository.GetRepositoryByOwnerName(ctx, repoOwner, repoName)
+	if err != nil {
+		log.Printf(" Failed to load repository %s/%s: %v", repoOwner, repoName, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load repository"})
+		return
+	}
+	if repo == nil || repo.Provider != service.ProviderGitHub {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+	cfg, err := h.ConfigRepository.GetByRepoID(ctx, repo.ID)
+	if err != nil {
+		log.Printf(" Failed to load config of %s/%s: %v", repoOwner, repoName, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load config"})
+		return
+	}
+	if cfg == nil || !service.MatchBranch(cfg.PushBranches, branch) {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	task, err := worker.NewPushReviewTask(worker.PushPayload{
+		RepoName:  repoName,
+		RepoOwner: repoOwner,
+		Branch:    branch,
+		BeforeSHA: e.GetBefore(),
+		HeadSHA:   e.GetAfter(),
+		Pusher:    e.GetPusher().GetName(),
+
+		InstallationID: e.GetInstallation().GetID(),
+	})
+	if err != nil {
+		log.Printf("Failed to create push review task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Error"})
+		return
+	}
+
+	taskID := fmt.Sprintf("push:%s:%s..%s", e.GetRepo().GetFullName(), e.GetBefore(), e.GetAfter())
+	if _, err := h.Client.Enqueue(task, asynq.TaskID(taskID), asynq.Retention(1*time.Hour)); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			log.Printf(" Duplicate Push Review Task Ignored: %s", taskID)
+			c.JSON(http.StatusOK, gin.H{"status": "duplicate_ignored"})
+			return
+		}
+		log.Printf(" Failed to enqueue push review task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
+		return
+	}
+
+	log.Printf(" Push Review Job Enqueued for %s@%s", e.GetRepo().GetFullName(), branch)
+	c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
+}
@@ -1,29 +1,38 @@
 package handler
 
 import (
-	// "context"
+	"context"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
 	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v50/github" 
 	"golang.org/x/oauth2"
 )
 
+// webhookURL is where GitHub sends the events of registered repositories
+const webhookURL = "https://verona-unabolished-ivy.ngrok-free.dev/webhook"
+
 type RepoHandler struct {
 	RepoRepository   *repository.RepoRepository
 	ConfigRepository *repository.ConfigRepository
 	UserRepository   *repository.UserRepository
+	Secrets          *service.SecretBox // Encrypts per-repository webhook secrets
 }
 
 type AddRepoRequest struct {
-	Name  string `json:"name" binding:"required"`
-	Owner string `json:"owner" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Owner    string `json:"owner" binding:"required"` // Namespace path on GitLab
+	Provider string `json:"provider"`                 // github (default), gitlab or gitea
+	BaseURL  string `json:"base_url"`                 // Instance URL; required for gitea, defaults to GITLAB_URL for gitlab
 }
 
 
@@ -40,7 +49,29 @@ func (h *RepoHandler) RegisterRepository(c *gin.Context) {
 		return
 	}
 
-	repo, err := h.RepoRepository.CreateRepository(c.Request.Context(), userID, req.Name, req.Owner)
+	switch req.Provider {
+	case "":
+		req.Provider = service.ProviderGitHub
+	case service.ProviderGitHub, service.ProviderGitLab, service.ProviderGitea:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provider must be github, gitlab or gitea"})
+		return
+	}
+
+	if req.Provider == service.ProviderGitea && req.BaseURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "base_url is required for gitea"})
+		return
+	}
+	if req.BaseURL != "" {
+		u, err := url.Parse(req.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "base_url must be an http(s) URL"})
+			return
+		}
+		req.BaseURL = strings.TrimRight(req.BaseURL, "/")
+	}
+
+	repo, err := h.RepoRepository.CreateRepository(c.Request.Context(), userID, req.Name, req.Owner, req.Provider, req.BaseURL)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create repository"})
 		return
@@ -82,6 +113,31 @@ func (h *RepoHandler) UpdateConfig(c *gin.Context) {
 		return
 	}
 	config.RepositoryID = repoID
+	switch config.SummaryMode {
+	case "":
+		config.SummaryMode = "comment"
+	case "off", "description", "comment":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "summary_mode must be one of off, description, comment"})
+		return
+	}
+	scanner, err := service.NewSecretScanner(config.SecretPatterns, config.SecretAllowlist)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := service.NewRedactor(scanner, config.SensitivePatterns); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := service.ValidateGlobs(config.GeneratedPatterns + "\n" + config.HotspotPatterns + "\n" + config.PushBranches); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if l := config.Labels; l.HighThreshold > 0 && l.MediumThreshold > 0 && l.HighThreshold < l.MediumThreshold {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "labels.high_threshold must not be below labels.medium_threshold"})
+		return
+	}
 	if err := h.ConfigRepository.UpsertConfig(c.Request.Context(), &config); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config"})
 		return
@@ -100,10 +156,14 @@ func (h *RepoHandler) CreateWebhook(c *gin.Context) {
 	repoID, _ := strconv.Atoi(c.Param("id"))
 
 	repo, err := h.RepoRepository.GetRepositoryByID(c.Request.Context(), repoID)
-	if err != nil || repo == nil {
+	if err != nil || repo == nil || repo.UserID != userID {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
 		return
 	}
+	if repo.Provider != service.ProviderGitHub {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhooks are only created automatically on GitHub"})
+		return
+	}
 
 	user, err := h.UserRepository.GetUserByID(c.Request.Context(), userID)
 	if err != nil || user.AccessToken == "" {
@@ -114,11 +174,21 @@ func (h *RepoHandler) CreateWebhook(c *gin.Context) {
 	ctx := c.Request.Context()
 	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: user.AccessToken})
 	tc := oauth2.NewClient(ctx, ts)
-	client := github.NewClient(tc)
+	client := service.NewGitHubClient(tc)
 
-	webhookURL := "https://verona-unabolished-ivy.ngrok-free.dev/webhook" 
-	
+	// Each repository signs with its own secret when we can store it encrypted
 	webhookSecret := os.Getenv("GITHUB_WEBHOOK_SECRET")
+	sealedSecret := ""
+	if h.Secrets != nil {
+		webhookSecret, err = service.GenerateWebhookSecret()
+		if err == nil {
+			sealedSecret, err = h.Secrets.Seal(webhookSecret)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook secret"})
+			return
+		}
+	}
 
 	hookConfig := map[string]interface{}{
 		"url":          webhookURL,
@@ -129,15 +199,21 @@ func (h *RepoHandler) CreateWebhook(c *gin.Context) {
 	hook := &github.Hook{
 		Name:   github.String("web"),
 		Active: github.Bool(true),
-		Events: []string{"pull_request"},
+		Events: []string{"pull_request", "pull_request_review_comment", "issue_comment", "push"},
 		Config: hookConfig,
 	}
 
-	_, _, err = client.Repositories.CreateHook(ctx, repo.Owner, repo.Name, hook)
+	created, _, err := client.Repositories.CreateHook(ctx, repo.Owner, repo.Name, hook)
 	if err != nil {
 		if errResp, ok := err.(*github.ErrorResponse); ok && errResp.Response.StatusCode == 422 {
-			log.Println(" Webhook already exists, treating as success.")
-			c.JSON(http.StatusOK, gin.H{"message": "Webhook already active"})
+			if h.Secrets == nil {
+				log.Println(" Webhook already exists, treating as success.")
+				c.JSON(http.StatusOK, gin.H{"message": "Webhook already active"})
+				return
+			}
+			// The existing hook keeps whatever secret it was made with, which
+			// is not stored for this repository
+			c.JSON(http.StatusConflict, gin.H{"error": "A webhook already exists for this repository. Rotate its secret to give it its own: POST /api/v1/repositories/" + strconv.Itoa(repo.ID) + "/webhook/rotate-secret"})
 			return
 		}
 
@@ -146,8 +222,136 @@ func (h *RepoHandler) CreateWebhook(c *gin.Context) {
 		return
 	}
 
+	saved := &model.RepoWebhook{RepositoryID: repo.ID, HookID: created.GetID(), Secret: sealedSecret}
+	if err := h.RepoRepository.SaveWebhook(ctx, saved); err != nil {
+		// Nothing could verify the hook's deliveries, so don't leave it behind
+		log.Printf("Failed to save webhook secret: %v", err)
+		client.Repositories.DeleteHook(ctx, repo.Owner, repo.Name, created.GetID())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save webhook"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Webhook created successfully!"})
 }
+
+// RotateWebhookSecret - Handles POST /api/v1/repositories/:id/webhook/rotate-secret
+// The hook gets a new secret; the old one is accepted for WebhookSecretGracePeriod
+// so deliveries already in flight still verify.
+func (h *RepoHandler) RotateWebhookSecret(c *gin.Context) {
+	userID := getUserIDFromToken(c)
+	if userID == 0 {
+		return
+	}
+	if h.Secrets == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhook secrets can't be stored: SECRET_ENCRYPTION_KEY is not set"})
+		return
+	}
+
+	repoID, _ := strconv.Atoi(c.Param("id"))
+	repo, err := h.RepoRepository.GetRepositoryByID(c.Request.Context(), repoID)
+	if err != nil || repo == nil || repo.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return
+	}
+
+	user, err := h.UserRepository.GetUserByID(c.Request.Context(), userID)
+	if err != nil || user.AccessToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "GitHub token not found. Please logout and login again."})
+		return
+	}
+
+	ctx := c.Request.Context()
+	client := service.NewGitHubClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: user.AccessToken})))
+
+	current, err := h.RepoRepository.GetWebhook(ctx, repo.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load webhook"})
+		return
+	}
+	hookID := current.HookID
+	if hookID == 0 {
+		hookID = findWebhook(ctx, client, repo)
+	}
+	if hookID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No webhook found, create it first"})
+		return
+	}
+	existing, _, err := client.Repositories.GetHook(ctx, repo.Owner, repo.Name, hookID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to load webhook from GitHub: " + err.Error()})
+		return
+	}
+
+	newSecret, err := service.GenerateWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook secret"})
+		return
+	}
+	sealedNew, err := h.Secrets.Seal(newSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook secret"})
+		return
+	}
+
+	// Hooks created before per-repository secrets were signed with the shared one
+	sealedOld := current.Secret
+	if sealedOld == "" && os.Getenv("GITHUB_WEBHOOK_SECRET") != "" {
+		if sealedOld, err = h.Secrets.Seal(os.Getenv("GITHUB_WEBHOOK_SECRET")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook secret"})
+			return
+		}
+	}
+
+	expires := time.Now().Add(WebhookSecretGracePeriod)
+	rotated := &model.RepoWebhook{
+		RepositoryID:      repo.ID,
+		HookID:            hookID,
+		Secret:            sealedNew,
+		PreviousSecret:    sealedOld,
+		PreviousExpiresAt: &expires,
+	}
+
+	// Save first: during the grace period both secrets verify, so deliveries
+	// signed either way pass while GitHub switches over
+	if err := h.RepoRepository.SaveWebhook(ctx, rotated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save webhook secret"})
+		return
+	}
+
+	config := existing.Config
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	config["secret"] = newSecret
+	if _, _, err := client.Repositories.EditHook(ctx, repo.Owner, repo.Name, hookID, &github.Hook{Config: config}); err != nil {
+		log.Printf("Failed to rotate webhook secret on GitHub: %v", err)
+		if err := h.RepoRepository.SaveWebhook(ctx, current); err != nil {
+			log.Printf("Failed to restore webhook secret of repository %d: %v", repo.ID, err)
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to update webhook on GitHub: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":                    "Webhook secret rotated",
+		"previous_secret_expires_at": expires,
+	})
+}
+
+// findWebhook looks up our hook on a repository registered before hook IDs
+// were stored
+func findWebhook(ctx context.Context, client *github.Client, repo *model.Repository) int64 {
+	hooks, _, err := client.Repositories.ListHooks(ctx, repo.Owner, repo.Name, nil)
+	if err != nil {
+		return 0
+	}
+	for _, hook := range hooks {
+		if url, ok := hook.Config["url"].(string); ok && url == webhookURL {
+			return hook.GetID()
+		}
+	}
+	return 0
+}
 func (h *RepoHandler) DeleteRepository(c *gin.Context) {
 	userID := getUserIDFromToken(c)
 	if userID == 0 { return }
@@ -167,7 +371,7 @@ func (h *RepoHandler) DeleteRepository(c *gin.Context) {
 	
 	ctx := c.Request.Context()
 	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: user.AccessToken})
-	client := github.NewClient(oauth2.NewClient(ctx, ts))
+	client := service.NewGitHubClient(oauth2.NewClient(ctx, ts))
 
 	hooks, _, err := client.Repositories.ListHooks(ctx, repo.Owner, repo.Name, nil)
 	if err == nil {
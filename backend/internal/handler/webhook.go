@@ -1,94 +1,200 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
 	"github.com/DHRUVV23/ai-code-review/backend/internal/worker"
 	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v50/github"
 	"github.com/hibiken/asynq"
 )
 
+// Labels that let a PR author steer the bot
+const (
+	LabelSkipReview  = "skip-ai-review"
+	LabelForceReview = "ai-review"
+)
+
 type WebhookHandler struct {
-	Client *asynq.Client
+	Client             *asynq.Client
+	Inspector          *asynq.Inspector
+	DeliveryRepository *repository.DeliveryRepository
+	RepoRepository     *repository.RepoRepository
+	ConfigRepository   *repository.ConfigRepository // Branches whose pushes are reviewed
+	Secrets            *service.SecretBox // Decrypts per-repository webhook secrets
+
+	InstallationRepository *repository.InstallationRepository
+	UserRepository         *repository.UserRepository
 }
 
 func (h *WebhookHandler) HandleWebhook(c *gin.Context) {
-	
-	webhookSecret := os.Getenv("GITHUB_WEBHOOK_SECRET")
-	payload, err := github.ValidatePayload(c.Request, []byte(webhookSecret))
+	delivery := &model.WebhookDelivery{
+		DeliveryID: c.GetHeader("X-GitHub-Delivery"),
+		Event:      github.WebHookType(c.Request),
+	}
+
+	payload, err := h.validatePayload(c)
 	if err != nil {
 		log.Printf("Invalid signature: %v", err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		delivery.StatusCode = http.StatusUnauthorized
+		delivery.Outcome = err.Error()
+		h.recordDelivery(c, delivery)
 		return
 	}
 
+	delivery.SignatureValid = true
+	delivery.Payload = string(payload)
 
-	event, err := github.ParseWebHook(github.WebHookType(c.Request), payload)
+	// GitHub redelivers, and several pods may get the same delivery
+	if !h.claimDelivery(c, delivery.DeliveryID) {
+		log.Printf(" Duplicate delivery ignored: %s", delivery.DeliveryID)
+		c.JSON(http.StatusOK, gin.H{"status": "duplicate_delivery"})
+		delivery.StatusCode = http.StatusOK
+		delivery.Outcome = "duplicate_delivery"
+		h.recordDelivery(c, delivery)
+		return
+	}
+
+	h.processAndRecord(c, delivery)
+	h.settleDelivery(c, delivery)
+}
+
+// processEvent dispatches a verified webhook payload
+func (h *WebhookHandler) processEvent(c *gin.Context, eventType string, payload []byte) {
+	event, err := github.ParseWebHook(eventType, payload)
 	if err != nil {
 		log.Printf("Could not parse webhook: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not parse webhook"})
 		return
 	}
 
+	if h.installationSuspended(c.Request.Context(), event) {
+		log.Printf(" Ignoring %s event: installation is suspended", eventType)
+		c.JSON(http.StatusOK, gin.H{"status": "installation_suspended"})
+		return
+	}
+
 	switch e := event.(type) {
 	case *github.PullRequestEvent:
-		action := e.GetAction()
+		h.handlePullRequestEvent(c, e)
+		return
+
+	case *github.PullRequestReviewCommentEvent:
+		comment := e.GetComment()
 
-	
-		if action != "opened" && action != "synchronize" {
-			log.Printf("Ignoring PR action: %s (we only care about 'opened' or 'synchronize')", action)
+		// Only replies in an existing thread matter, and never our own replies
+		if e.GetAction() != "created" || comment.GetInReplyTo() == 0 {
+			c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+			return
+		}
+		if strings.Contains(comment.GetBody(), service.BotCommentMarker) || comment.GetUser().GetType() == "Bot" {
 			c.JSON(http.StatusOK, gin.H{"status": "ignored"})
 			return
 		}
 
 		repo := e.GetRepo()
-		prNumber := e.GetNumber()
-		repoName := repo.GetName()
-		repoOwner := repo.GetOwner().GetLogin()
-		repoID := int(repo.GetID())
-
-	
-		commitSHA := e.GetPullRequest().GetHead().GetSHA()
-
-		log.Printf(" Processing PR #%d for %s/%s (Commit: %s)", prNumber, repoOwner, repoName, commitSHA)
+		task, err := worker.NewReplyTask(worker.ReplyPayload{
+			RepoName:  repo.GetName(),
+			RepoOwner: repo.GetOwner().GetLogin(),
+			PRNumber:  e.GetPullRequest().GetNumber(),
+			CommentID: comment.GetID(),
+			InReplyTo: comment.GetInReplyTo(),
+			Author:    comment.GetUser().GetLogin(),
+			Body:      comment.GetBody(),
 
-	
-		task, err := worker.NewReviewTask(repoName, repoOwner, prNumber, int64(repoID))
+			InstallationID: e.GetInstallation().GetID(),
+		})
 		if err != nil {
-			log.Printf("Failed to create task: %v", err)
+			log.Printf("Failed to create reply task: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Error"})
 			return
 		}
 
+		taskID := fmt.Sprintf("reply:%s:%d", repo.GetFullName(), comment.GetID())
+		if _, err := h.Client.Enqueue(task, asynq.TaskID(taskID), asynq.Retention(1*time.Hour)); err != nil {
+			if errors.Is(err, asynq.ErrTaskIDConflict) {
+				log.Printf(" Duplicate Reply Task Ignored: %s", taskID)
+				c.JSON(http.StatusOK, gin.H{"status": "duplicate_ignored"})
+				return
+			}
+			log.Printf(" Failed to enqueue reply task: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
+			return
+		}
 
-		taskID := fmt.Sprintf("review:%s/%s:%d:%s", repoOwner, repoName, prNumber, commitSHA)
+		log.Printf(" Reply Job Enqueued for comment %d on PR #%d", comment.GetID(), e.GetPullRequest().GetNumber())
 
-		info, err := h.Client.Enqueue(task,
-			asynq.TaskID(taskID),          
-			asynq.Retention(1*time.Hour),   
-		)
+	case *github.IssueCommentEvent:
+		body := strings.TrimSpace(e.GetComment().GetBody())
 
+		// "/ai-fix" on a PR conversation applies the review's suggestions
+		if e.GetAction() != "created" || !e.GetIssue().IsPullRequest() || !strings.HasPrefix(body, "/ai-fix") {
+			c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+			return
+		}
+
+		// The bot pushes with its own credentials, so the commenter needs write access
+		allowed, err := authorizeAutofix(c.Request.Context(), e)
 		if err != nil {
-		
-			if strings.Contains(err.Error(), "task ID conflicts") {
-				log.Printf(" Duplicate Review Task Ignored: %s", taskID)
+			log.Printf(" Failed to check /ai-fix permission of %s: %v", e.GetComment().GetUser().GetLogin(), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permission"})
+			return
+		}
+		if !allowed {
+			log.Printf(" Refused /ai-fix from %s on PR #%d", e.GetComment().GetUser().GetLogin(), e.GetIssue().GetNumber())
+			c.JSON(http.StatusOK, gin.H{"status": "forbidden"})
+			return
+		}
+
+		repo := e.GetRepo()
+		task, err := worker.NewAutofixTask(worker.AutofixPayload{
+			RepoName:    repo.GetName(),
+			RepoOwner:   repo.GetOwner().GetLogin(),
+			PRNumber:    e.GetIssue().GetNumber(),
+			RequestedBy: e.GetComment().GetUser().GetLogin(),
+
+			InstallationID: e.GetInstallation().GetID(),
+		})
+		if err != nil {
+			log.Printf("Failed to create autofix task: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Error"})
+			return
+		}
+
+		taskID := fmt.Sprintf("autofix:%s:%d:%d", repo.GetFullName(), e.GetIssue().GetNumber(), e.GetComment().GetID())
+		if _, err := h.Client.Enqueue(task, asynq.TaskID(taskID), asynq.Retention(1*time.Hour)); err != nil {
+			if errors.Is(err, asynq.ErrTaskIDConflict) {
+				log.Printf(" Duplicate Autofix Task Ignored: %s", taskID)
 				c.JSON(http.StatusOK, gin.H{"status": "duplicate_ignored"})
 				return
 			}
-
-			
-			log.Printf(" Failed to enqueue task: %v", err)
+			log.Printf(" Failed to enqueue autofix task: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
 			return
 		}
 
-		log.Printf(" Review Job Enqueued! ID: %s", info.ID)
+		log.Printf(" Autofix Job Enqueued for PR #%d", e.GetIssue().GetNumber())
+
+	case *github.PushEvent:
+		h.handlePushEvent(c, e)
+		return
+
+	case *github.InstallationEvent:
+		h.handleInstallationEvent(c, e)
+		return
+
+	case *github.InstallationRepositoriesEvent:
+		h.handleInstallationRepositoriesEvent(c, e)
+		return
 
 	case *github.PingEvent:
 		log.Println(" GitHub Ping! Connection verified.")
@@ -98,4 +204,136 @@ func (h *WebhookHandler) HandleWebhook(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
-}
\ No newline at end of file
+}
+
+// handlePullRequestEvent queues reviews for new and updated PRs, honours the
+// skip/force labels, and cleans up after closed PRs
+func (h *WebhookHandler) handlePullRequestEvent(c *gin.Context, e *github.PullRequestEvent) {
+	action := e.GetAction()
+	pr := e.GetPullRequest()
+	repo := e.GetRepo()
+	prNumber := e.GetNumber()
+	repoName := repo.GetName()
+	repoOwner := repo.GetOwner().GetLogin()
+
+	if action == "closed" {
+		h.handleClosedPR(c, e)
+		return
+	}
+
+	force := action == "labeled" && e.GetLabel().GetName() == LabelForceReview
+	switch action {
+	case "opened", "synchronize", "reopened", "ready_for_review":
+	default:
+		if !force {
+			log.Printf("Ignoring PR action: %s", action)
+			c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+			return
+		}
+	}
+
+	for _, label := range pr.Labels {
+		if label.GetName() == LabelSkipReview {
+			log.Printf(" Skipping PR #%d: labeled %s", prNumber, LabelSkipReview)
+			c.JSON(http.StatusOK, gin.H{"status": "skipped"})
+			return
+		}
+	}
+
+	commitSHA := pr.GetHead().GetSHA()
+	log.Printf(" Processing PR #%d for %s/%s (Commit: %s)", prNumber, repoOwner, repoName, commitSHA)
+
+	task, err := worker.NewReviewTask(worker.ReviewPayload{
+		RepoName:  repoName,
+		RepoOwner: repoOwner,
+		PRNumber:  prNumber,
+		RepoID:    repo.GetID(),
+		HeadSHA:   commitSHA,
+		BaseSHA:   pr.GetBase().GetSHA(),
+		Draft:     pr.GetDraft(),
+		Force:     force,
+
+		InstallationID: e.GetInstallation().GetID(),
+	})
+	if err != nil {
+		log.Printf("Failed to create task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Error"})
+		return
+	}
+
+	taskID := fmt.Sprintf("review:%s/%s:%d:%s", repoOwner, repoName, prNumber, commitSHA)
+	if force {
+		taskID += ":forced"
+	}
+
+	info, err := h.Client.Enqueue(task,
+		asynq.TaskID(taskID),
+		asynq.Retention(1*time.Hour),
+	)
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			log.Printf(" Duplicate Review Task Ignored: %s", taskID)
+			c.JSON(http.StatusOK, gin.H{"status": "duplicate_ignored"})
+			return
+		}
+		log.Printf(" Failed to enqueue task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
+		return
+	}
+
+	log.Printf(" Review Job Enqueued! ID: %s", info.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
+}
+
+// handleClosedPR cancels the PR's queued work and, if it was merged, records
+// its final state
+func (h *WebhookHandler) handleClosedPR(c *gin.Context, e *github.PullRequestEvent) {
+	pr := e.GetPullRequest()
+	repo := e.GetRepo()
+
+	if h.Inspector != nil {
+		cancelled, err := worker.CancelPRTasks(h.Inspector, service.ProviderGitHub, repo.GetOwner().GetLogin(), repo.GetName(), e.GetNumber())
+		if err != nil {
+			log.Printf(" Failed to cancel tasks of PR #%d: %v", e.GetNumber(), err)
+		} else if cancelled > 0 {
+			log.Printf(" Cancelled %d task(s) of closed PR #%d", cancelled, e.GetNumber())
+		}
+	}
+
+	if !pr.GetMerged() {
+		c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
+		return
+	}
+
+	task, err := worker.NewMergedTask(worker.MergedPayload{
+		RepoName:       repo.GetName(),
+		RepoOwner:      repo.GetOwner().GetLogin(),
+		PRNumber:       e.GetNumber(),
+		MergedAt:       pr.GetMergedAt().Time,
+		MergeCommitSHA: pr.GetMergeCommitSHA(),
+		HeadSHA:        pr.GetHead().GetSHA(),
+		Additions:      pr.GetAdditions(),
+		Deletions:      pr.GetDeletions(),
+		ChangedFiles:   pr.GetChangedFiles(),
+	})
+	if err != nil {
+		log.Printf("Failed to create merged task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Error"})
+		return
+	}
+
+	taskID := fmt.Sprintf("merged:%s:%d", repo.GetFullName(), e.GetNumber())
+	if _, err := h.Client.Enqueue(task, asynq.TaskID(taskID), asynq.Retention(1*time.Hour)); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			log.Printf(" Duplicate Merged Task Ignored: %s", taskID)
+			c.JSON(http.StatusOK, gin.H{"status": "duplicate_ignored"})
+			return
+		}
+		log.Printf(" Failed to enqueue merged task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
+		return
+	}
+
+	log.Printf(" Merge of PR #%d queued for analytics", e.GetNumber())
+	c.JSON(http.StatusOK, gin.H{"message": "Event processed"})
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v50/github"
+)
+
+// WebhookSecretGracePeriod is how long the old secret keeps working after a rotation
+const WebhookSecretGracePeriod = 24 * time.Hour
+
+// validatePayload checks the delivery signature against the secrets of the
+// repository it is for. Repositories without their own secret and events
+// that aren't about a repository use GITHUB_WEBHOOK_SECRET; GitHub App
+// deliveries are also checked against GITHUB_APP_WEBHOOK_SECRET.
+func (h *WebhookHandler) validatePayload(c *gin.Context) ([]byte, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := c.GetHeader(github.SHA256SignatureHeader)
+	if signature == "" {
+		signature = c.GetHeader(github.SHA1SignatureHeader)
+	}
+	contentType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = errors.New("no webhook secret to validate against")
+	for _, secret := range h.webhookSecrets(c.Request.Context(), contentType, body) {
+		var payload []byte
+		payload, err = github.ValidatePayloadFromBody(contentType, bytes.NewReader(body), signature, secret)
+		if err == nil {
+			return payload, nil
+		}
+	}
+	return nil, err
+}
+
+// webhookSecrets returns every secret a delivery for the repository named in
+// the (not yet verified) body may be signed with. Claiming to come from an
+// installation only adds the app's secret: the repository's own secrets
+// still apply, so the claim can't be used to fall back to the shared one.
+func (h *WebhookHandler) webhookSecrets(ctx context.Context, contentType string, body []byte) [][]byte {
+	fallback := []byte(os.Getenv("GITHUB_WEBHOOK_SECRET"))
+	owner, name, installed := peekRepository(contentType, body)
+
+	var secrets [][]byte
+	if installed {
+		if appSecret := os.Getenv("GITHUB_APP_WEBHOOK_SECRET"); appSecret != "" {
+			secrets = append(secrets, []byte(appSecret))
+		}
+	}
+	switch {
+	case owner != "" && h.RepoRepository != nil:
+		secrets = append(secrets, h.repoSecrets(ctx, service.ProviderGitHub, owner, name, fallback)...)
+	case owner == "" && installed:
+		// Installation events only ever come from the app
+	default:
+		secrets = append(secrets, fallback)
+	}
+	return secrets
+}
+
+// repoSecrets returns the secrets of the registrations of owner/name on the
+// code host, with fallback for registrations that have none. A stored secret
+// that can't be opened rejects the delivery rather than letting the shared
+// fallback stand in for it.
+func (h *WebhookHandler) repoSecrets(ctx context.Context, provider, owner, name string, fallback []byte) [][]byte {
+	hooks, err := h.RepoRepository.ListWebhooksByOwnerName(ctx, provider, owner, name)
+	if err != nil {
+		log.Printf(" Failed to load webhook secrets of %s/%s: %v", owner, name, err)
+		return nil
+	}
+
+	var secrets [][]byte
+	useFallback := len(hooks) == 0
+	for _, hook := range hooks {
+		if hook.Secret == "" {
+			useFallback = true
+			continue
+		}
+		opened, err := h.openSecrets(hook)
+		if err != nil {
+			log.Printf(" Rejecting delivery for %s/%s: %v", owner, name, err)
+			return nil
+		}
+		for _, secret := range opened {
+			secrets = append(secrets, []byte(secret))
+		}
+	}
+	if useFallback {
+		secrets = append(secrets, fallback)
+	}
+	return secrets
+}
+
+// openSecrets decrypts the current secret of a hook and, during its grace
+// period, the previous one. Only the current secret is required to open.
+func (h *WebhookHandler) openSecrets(hook model.RepoWebhook) ([]string, error) {
+	if h.Secrets == nil {
+		return nil, fmt.Errorf("repository %d has a webhook secret but SECRET_ENCRYPTION_KEY is not set", hook.RepositoryID)
+	}
+	current, err := h.Secrets.Open(hook.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("repository %d: %w", hook.RepositoryID, err)
+	}
+
+	secrets := []string{current}
+	if hook.PreviousSecret != "" && hook.PreviousExpiresAt != nil && time.Now().Before(*hook.PreviousExpiresAt) {
+		previous, err := h.Secrets.Open(hook.PreviousSecret)
+		if err != nil {
+			log.Printf(" Repository %d: %v", hook.RepositoryID, err)
+		} else {
+			secrets = append(secrets, previous)
+		}
+	}
+	return secrets, nil
+}
+
+// peekRepository reads repository.full_name from a webhook body, and
+// whether it was sent for a GitHub App installation
+func peekRepository(contentType string, body []byte) (owner, name string, installed bool) {
+	if contentType == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "", "", false
+		}
+		body = []byte(form.Get("payload"))
+	}
+
+	var meta struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+		Installation *struct {
+			ID int64 `json:"id"`
+		} `json:"installation"`
+	}
+	if json.Unmarshal(body, &meta) != nil {
+		return "", "", false
+	}
+	owner, name, _ = strings.Cut(meta.Repository.FullName, "/")
+	return owner, name, meta.Installation != nil && meta.Installation.ID != 0
+}
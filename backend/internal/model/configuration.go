@@ -3,9 +3,34 @@ package model
 import "time"
 
 type Configuration struct {
-	ID             int       `json:"id"`
-	RepositoryID   int       `json:"repository_id"`
-	IgnorePatterns string    `json:"ignore_patterns"` 
-	ReviewStyle    string    `json:"review_style"`
-	CreatedAt      time.Time `json:"created_at"`
-}
\ No newline at end of file
+	ID                 int           `json:"id"`
+	RepositoryID       int           `json:"repository_id"`
+	IgnorePatterns     string        `json:"ignore_patterns"`
+	ReviewStyle        string        `json:"review_style"`
+	SummaryMode        string        `json:"summary_mode"`         // "off", "description" or "comment"
+	SecretPatterns     string        `json:"secret_patterns"`      // Extra regexes, one per line
+	SecretAllowlist    string        `json:"secret_allowlist"`     // Regexes for known-safe values or paths, one per line
+	SecretScanBlocking bool          `json:"secret_scan_blocking"` // Fail the status check and skip the AI review on a hit
+	SensitivePatterns  string        `json:"sensitive_patterns"`   // Regexes redacted before code is sent to the LLM, one per line
+	LicenseAllowlist   string        `json:"license_allowlist"`    // Allowed SPDX license IDs for new dependencies, empty allows any
+	GeneratedPatterns  string        `json:"generated_patterns"`   // Globs of generated files to skip, one per line
+	HotspotPatterns    string        `json:"hotspot_patterns"`     // Globs reviewed first when a PR is over budget, one per line
+	Labels             LabelSettings `json:"labels"`
+	OwnershipRules     string        `json:"ownership_rules"` // CODEOWNERS-style rules, used when the repo has no CODEOWNERS
+	ReviewDrafts       bool          `json:"review_drafts"`   // Review draft PRs too
+	PushBranches       string        `json:"push_branches"`   // Branch globs whose direct pushes are reviewed, one per line
+	CreatedAt          time.Time     `json:"created_at"`
+}
+
+// LabelSettings controls the labels applied after a review. Empty names and
+// zero thresholds use the defaults, and a name of "-" turns that label off.
+type LabelSettings struct {
+	Disabled        bool   `json:"disabled"`
+	MediumThreshold int    `json:"medium_threshold"` // Risk score from which the PR is medium risk
+	HighThreshold   int    `json:"high_threshold"`
+	RiskLow         string `json:"risk_low"`
+	RiskMedium      string `json:"risk_medium"`
+	RiskHigh        string `json:"risk_high"`
+	Security        string `json:"security"` // Applied when there is a security finding
+	LGTM            string `json:"lgtm"`     // Applied when nothing of medium or high severity was found
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// DataPolicy controls which paths may be sent to a model. A row with
+// RepositoryID 0 applies to every repository of Owner.
+type DataPolicy struct {
+	ID           int       `json:"id"`
+	Owner        string    `json:"owner"`
+	RepositoryID int       `json:"repository_id"`
+	NeverSend    string    `json:"never_send"` // Globs never sent to any model, one per line
+	LocalOnly    string    `json:"local_only"` // Globs only sent to self-hosted models, one per line
+	UpdatedAt    time.Time `json:"updated_at"`
+}
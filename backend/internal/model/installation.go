@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// Installation is a GitHub App installation on a user or organization account
+type Installation struct {
+	ID                   int       `json:"id"`
+	GithubInstallationID int64     `json:"github_installation_id"`
+	AccountLogin         string    `json:"account_login"`
+	AccountType          string    `json:"account_type"` // "User" or "Organization"
+	Suspended            bool      `json:"suspended"`
+	CreatedAt            time.Time `json:"created_at"`
+}
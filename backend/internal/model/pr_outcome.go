@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// PROutcome is the final state of a merged PR, next to what the bot found in it
+type PROutcome struct {
+	ID             int       `json:"id"`
+	RepositoryID   int       `json:"repository_id"`
+	PRNumber       int       `json:"pr_number"`
+	MergedAt       time.Time `json:"merged_at"`
+	MergeCommitSHA string    `json:"merge_commit_sha"`
+	HeadSHA        string    `json:"head_sha"`
+	Additions      int       `json:"additions"`
+	Deletions      int       `json:"deletions"`
+	ChangedFiles   int       `json:"changed_files"`
+	Reviews        int       `json:"reviews"`
+	Findings       int       `json:"findings"`
+	FalsePositives int       `json:"false_positives"`
+	CreatedAt      time.Time `json:"created_at"`
+}
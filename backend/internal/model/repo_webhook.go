@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// RepoWebhook is the GitHub hook of a repository and its signing secrets.
+// Secrets are stored encrypted; PreviousSecret keeps verifying deliveries
+// until PreviousExpiresAt so that a rotation drops nothing.
+type RepoWebhook struct {
+	RepositoryID      int        `json:"repository_id"`
+	HookID            int64      `json:"hook_id"`
+	Secret            string     `json:"-"`
+	PreviousSecret    string     `json:"-"`
+	PreviousExpiresAt *time.Time `json:"previous_expires_at,omitempty"`
+}
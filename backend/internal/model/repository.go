@@ -7,5 +7,7 @@ type Repository struct {
 	UserID    int       `json:"user_id"`
 	Name      string    `json:"name"`
 	Owner     string    `json:"owner"`
+	Provider  string    `json:"provider"`           // github, gitlab or gitea
+	BaseURL   string    `json:"base_url,omitempty"` // Self-hosted instance, e.g. https://gitea.example.com
 	CreatedAt time.Time `json:"created_at"`
 }
\ No newline at end of file
@@ -2,10 +2,19 @@ package model
 
 import "time"
 
+// What a review looked at
+const (
+	ReviewTargetPullRequest = "pull_request"
+	ReviewTargetCommitRange = "commit_range" // Commits pushed straight to a branch
+)
+
 type Review struct {
 	ID           int       `json:"id"`
 	RepositoryID int       `json:"repository_id"`
-	PRNumber     int       `json:"pr_number"`
+	TargetType   string    `json:"target_type"`
+	PRNumber     int       `json:"pr_number,omitempty"`
+	CommitRange  string    `json:"commit_range,omitempty"` // "before..after" of a push
+	CommitSHA    string    `json:"commit_sha"`
 	Status       string    `json:"status"` // e.g., "pending", "completed", "failed"
 	Content      string    `json:"content"` // The actual AI feedback
 	CreatedAt    time.Time `json:"created_at"`
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// ReviewIssue is a single finding stored for a review
+type ReviewIssue struct {
+	ID              int       `json:"id"`
+	ReviewID        int       `json:"review_id"`
+	FilePath        string    `json:"file_path"`
+	LineNumber      int       `json:"line_number"`
+	Severity        string    `json:"severity"`
+	Category        string    `json:"category"`
+	Message         string    `json:"message"`
+	Suggestion      string    `json:"suggestion"`
+	StartLine       int       `json:"start_line"`     // Replacement range for SuggestedCode, 0 if none
+	EndLine         int       `json:"end_line"`
+	SuggestedCode   string    `json:"suggested_code"`
+	Source          string    `json:"source"` // "ai" for the model, otherwise the built-in check that reported it
+	GithubCommentID int64     `json:"github_comment_id"` // Root of the inline thread, 0 if not posted inline
+	FalsePositive   bool      `json:"false_positive"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// ThreadMessage is one comment in the discussion under a finding
+type ThreadMessage struct {
+	ID              int       `json:"id"`
+	IssueID         int       `json:"issue_id"`
+	GithubCommentID int64     `json:"github_comment_id"`
+	Author          string    `json:"author"`
+	Body            string    `json:"body"`
+	FromBot         bool      `json:"from_bot"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// WebhookDelivery is one webhook request as we received and handled it
+type WebhookDelivery struct {
+	ID             int       `json:"id"`
+	DeliveryID     string    `json:"delivery_id"` // X-GitHub-Delivery
+	Event          string    `json:"event"`
+	Action         string    `json:"action"`
+	Repository     string    `json:"repository"` // owner/name
+	SignatureValid bool      `json:"signature_valid"`
+	StatusCode     int       `json:"status_code"`
+	Outcome        string    `json:"outcome"`
+	Payload        string    `json:"payload,omitempty"` // Cleared after the retention period
+	ReplayOf       *int      `json:"replay_of,omitempty"`
+	ReceivedAt     time.Time `json:"received_at"`
+}
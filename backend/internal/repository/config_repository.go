@@ -19,7 +19,12 @@ func NewConfigRepository(pool *pgxpool.Pool) *ConfigRepository {
 // GetByRepoID fetches the config exactly as it is in the DB
 func (r *ConfigRepository) GetByRepoID(ctx context.Context, repoID int) (*model.Configuration, error) {
 	query := `
-		SELECT id, repository_id, review_style, ignore_patterns, created_at
+		SELECT id, repository_id, review_style, ignore_patterns, COALESCE(summary_mode, 'comment'),
+			COALESCE(secret_patterns, ''), COALESCE(secret_allowlist, ''), COALESCE(secret_scan_blocking, FALSE),
+			COALESCE(sensitive_patterns, ''), COALESCE(license_allowlist, ''),
+			COALESCE(generated_patterns, ''), COALESCE(hotspot_patterns, ''),
+			COALESCE(labels, '{}'::jsonb), COALESCE(ownership_rules, ''),
+			COALESCE(review_drafts, FALSE), COALESCE(push_branches, ''), created_at
 		FROM configurations 
 		WHERE repository_id = $1`
 
@@ -29,6 +34,18 @@ func (r *ConfigRepository) GetByRepoID(ctx context.Context, repoID int) (*model.
 		&config.RepositoryID, 
 		&config.ReviewStyle, 
 		&config.IgnorePatterns, // Direct string scan
+		&config.SummaryMode,
+		&config.SecretPatterns,
+		&config.SecretAllowlist,
+		&config.SecretScanBlocking,
+		&config.SensitivePatterns,
+		&config.LicenseAllowlist,
+		&config.GeneratedPatterns,
+		&config.HotspotPatterns,
+		&config.Labels,
+		&config.OwnershipRules,
+		&config.ReviewDrafts,
+		&config.PushBranches,
 		&config.CreatedAt,
 	)
 
@@ -45,12 +62,27 @@ func (r *ConfigRepository) GetByRepoID(ctx context.Context, repoID int) (*model.
 // UpsertConfig updates the config
 func (r *ConfigRepository) UpsertConfig(ctx context.Context, config *model.Configuration) error {
 	query := `
-		INSERT INTO configurations (repository_id, review_style, ignore_patterns, updated_at)
-		VALUES ($1, $2, $3, NOW())
+		INSERT INTO configurations (repository_id, review_style, ignore_patterns, summary_mode,
+			secret_patterns, secret_allowlist, secret_scan_blocking, sensitive_patterns, license_allowlist,
+			generated_patterns, hotspot_patterns, labels, ownership_rules,
+			review_drafts, push_branches, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW())
 		ON CONFLICT (repository_id)
 		DO UPDATE SET 
 			review_style = $2, 
 			ignore_patterns = $3, 
+			summary_mode = $4,
+			secret_patterns = $5,
+			secret_allowlist = $6,
+			secret_scan_blocking = $7,
+			sensitive_patterns = $8,
+			license_allowlist = $9,
+			generated_patterns = $10,
+			hotspot_patterns = $11,
+			labels = $12,
+			ownership_rules = $13,
+			review_drafts = $14,
+			push_branches = $15,
 			updated_at = NOW()
 		RETURNING id`
 
@@ -58,5 +90,17 @@ func (r *ConfigRepository) UpsertConfig(ctx context.Context, config *model.Confi
 		config.RepositoryID, 
 		config.ReviewStyle, 
 		config.IgnorePatterns, // Direct string insert
+		config.SummaryMode,
+		config.SecretPatterns,
+		config.SecretAllowlist,
+		config.SecretScanBlocking,
+		config.SensitivePatterns,
+		config.LicenseAllowlist,
+		config.GeneratedPatterns,
+		config.HotspotPatterns,
+		config.Labels,
+		config.OwnershipRules,
+		config.ReviewDrafts,
+		config.PushBranches,
 	).Scan(&config.ID)
 }
\ No newline at end of file
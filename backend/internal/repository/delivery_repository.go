@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DeliveryRepository struct {
+	Pool *pgxpool.Pool
+}
+
+func NewDeliveryRepository(pool *pgxpool.Pool) *DeliveryRepository {
+	return &DeliveryRepository{Pool: pool}
+}
+
+// RecordDelivery stores a handled webhook delivery
+func (r *DeliveryRepository) RecordDelivery(ctx context.Context, d *model.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (delivery_id, event, action, repository, signature_valid,
+			status_code, outcome, payload, replay_of)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9)
+		RETURNING id, received_at`
+
+	err := r.Pool.QueryRow(ctx, query, d.DeliveryID, d.Event, d.Action, d.Repository, d.SignatureValid,
+		d.StatusCode, d.Outcome, d.Payload, d.ReplayOf).Scan(&d.ID, &d.ReceivedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the latest deliveries, newest first, without their
+// payloads. Empty filters match everything.
+func (r *DeliveryRepository) ListDeliveries(ctx context.Context, repo, event string, limit int) ([]model.WebhookDelivery, error) {
+	query := `
+		SELECT id, COALESCE(delivery_id, ''), COALESCE(event, ''), COALESCE(action, ''), COALESCE(repository, ''),
+			signature_valid, status_code, COALESCE(outcome, ''), replay_of, received_at
+		FROM webhook_deliveries
+		WHERE ($1 = '' OR repository = $1) AND ($2 = '' OR event = $2)
+		ORDER BY received_at DESC, id DESC
+		LIMIT $3`
+
+	rows, err := r.Pool.Query(ctx, query, repo, event, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var d model.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.DeliveryID, &d.Event, &d.Action, &d.Repository,
+			&d.SignatureValid, &d.StatusCode, &d.Outcome, &d.ReplayOf, &d.ReceivedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetDelivery returns one delivery with its payload, nil if it doesn't exist
+func (r *DeliveryRepository) GetDelivery(ctx context.Context, id int) (*model.WebhookDelivery, error) {
+	query := `
+		SELECT id, COALESCE(delivery_id, ''), COALESCE(event, ''), COALESCE(action, ''), COALESCE(repository, ''),
+			signature_valid, status_code, COALESCE(outcome, ''), COALESCE(payload, ''), replay_of, received_at
+		FROM webhook_deliveries
+		WHERE id = $1`
+
+	var d model.WebhookDelivery
+	err := r.Pool.QueryRow(ctx, query, id).Scan(&d.ID, &d.DeliveryID, &d.Event, &d.Action, &d.Repository,
+		&d.SignatureValid, &d.StatusCode, &d.Outcome, &d.Payload, &d.ReplayOf, &d.ReceivedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+	return &d, nil
+}
+
+// PrunePayloads drops the payloads of deliveries received before cutoff and
+// returns how many were dropped. The rest of the record is kept.
+func (r *DeliveryRepository) PrunePayloads(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := r.Pool.Exec(ctx, `UPDATE webhook_deliveries SET payload = NULL WHERE payload IS NOT NULL AND received_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune webhook payloads: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ClaimDelivery records that deliveryID is being processed. It returns false
+// if another request already holds an unexpired claim on it.
+func (r *DeliveryRepository) ClaimDelivery(ctx context.Context, deliveryID string, ttl time.Duration) (bool, error) {
+	query := `
+		INSERT INTO webhook_dedup (delivery_id, claimed_at, expires_at)
+		VALUES ($1, NOW(), NOW() + make_interval(secs => $2))
+		ON CONFLICT (delivery_id) DO UPDATE SET
+			claimed_at = NOW(),
+			expires_at = NOW() + make_interval(secs => $2)
+		WHERE webhook_dedup.expires_at < NOW()
+		RETURNING delivery_id`
+
+	var claimed string
+	err := r.Pool.QueryRow(ctx, query, deliveryID, ttl.Seconds()).Scan(&claimed)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to claim delivery: %w", err)
+	}
+	return true, nil
+}
+
+// ExtendClaim keeps the claim on a processed delivery for ttl from now
+func (r *DeliveryRepository) ExtendClaim(ctx context.Context, deliveryID string, ttl time.Duration) error {
+	query := `UPDATE webhook_dedup SET expires_at = NOW() + make_interval(secs => $2) WHERE delivery_id = $1`
+	if _, err := r.Pool.Exec(ctx, query, deliveryID, ttl.Seconds()); err != nil {
+		return fmt.Errorf("failed to extend delivery claim: %w", err)
+	}
+	return nil
+}
+
+// ReleaseDelivery drops a claim so that a redelivery is processed again
+func (r *DeliveryRepository) ReleaseDelivery(ctx context.Context, deliveryID string) error {
+	_, err := r.Pool.Exec(ctx, `DELETE FROM webhook_dedup WHERE delivery_id = $1`, deliveryID)
+	return err
+}
+
+// PurgeExpiredClaims deletes the claims that no longer block anything
+func (r *DeliveryRepository) PurgeExpiredClaims(ctx context.Context) (int64, error) {
+	tag, err := r.Pool.Exec(ctx, `DELETE FROM webhook_dedup WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge delivery claims: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type InstallationRepository struct {
+	Pool *pgxpool.Pool
+}
+
+func NewInstallationRepository(pool *pgxpool.Pool) *InstallationRepository {
+	return &InstallationRepository{Pool: pool}
+}
+
+// UpsertInstallation records an installation of the app
+func (r *InstallationRepository) UpsertInstallation(ctx context.Context, i *model.Installation) error {
+	query := `
+		INSERT INTO installations (github_installation_id, account_login, account_type, suspended)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (github_installation_id)
+		DO UPDATE SET
+			account_login = $2,
+			account_type = $3,
+			suspended = $4
+		RETURNING id, created_at`
+
+	err := r.Pool.QueryRow(ctx, query, i.GithubInstallationID, i.AccountLogin, i.AccountType, i.Suspended).Scan(&i.ID, &i.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save installation: %w", err)
+	}
+	return nil
+}
+
+// SetSuspended marks an installation as suspended or active again
+func (r *InstallationRepository) SetSuspended(ctx context.Context, githubInstallationID int64, suspended bool) error {
+	_, err := r.Pool.Exec(ctx, `UPDATE installations SET suspended = $2 WHERE github_installation_id = $1`, githubInstallationID, suspended)
+	return err
+}
+
+// IsSuspended reports whether the installation is suspended. Unknown
+// installations are not.
+func (r *InstallationRepository) IsSuspended(ctx context.Context, githubInstallationID int64) (bool, error) {
+	var suspended bool
+	err := r.Pool.QueryRow(ctx, `SELECT COALESCE(suspended, FALSE) FROM installations WHERE github_installation_id = $1`, githubInstallationID).Scan(&suspended)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get installation: %w", err)
+	}
+	return suspended, nil
+}
+
+// DeleteInstallation removes an uninstalled app and the repositories it
+// registered. Repositories users registered themselves are only unlinked.
+func (r *InstallationRepository) DeleteInstallation(ctx context.Context, githubInstallationID int64) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := unlinkInstallation(ctx, tx, githubInstallationID, "", ""); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM installations WHERE github_installation_id = $1`, githubInstallationID); err != nil {
+		return fmt.Errorf("failed to delete installation: %w", err)
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IssueRepository struct {
+	Pool *pgxpool.Pool
+}
+
+func NewIssueRepository(pool *pgxpool.Pool) *IssueRepository {
+	return &IssueRepository{Pool: pool}
+}
+
+// CreateIssue stores a finding and fills in its ID
+func (r *IssueRepository) CreateIssue(ctx context.Context, issue *model.ReviewIssue) error {
+	query := `
+		INSERT INTO review_issues (review_id, file_path, line_number, severity, category, message, suggestion, start_line, end_line, suggested_code, github_comment_id, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE(NULLIF($12, ''), 'ai'))
+		RETURNING id, created_at`
+
+	err := r.Pool.QueryRow(ctx, query,
+		issue.ReviewID,
+		issue.FilePath,
+		issue.LineNumber,
+		issue.Severity,
+		issue.Category,
+		issue.Message,
+		issue.Suggestion,
+		issue.StartLine,
+		issue.EndLine,
+		issue.SuggestedCode,
+		issue.GithubCommentID,
+		issue.Source,
+	).Scan(&issue.ID, &issue.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert issue: %w", err)
+	}
+	return nil
+}
+
+// GetByCommentID finds the finding whose inline comment started a thread
+func (r *IssueRepository) GetByCommentID(ctx context.Context, commentID int64) (*model.ReviewIssue, error) {
+	query := `
+		SELECT id, review_id, file_path, line_number, severity, category, message, COALESCE(suggestion, ''),
+			start_line, end_line, COALESCE(suggested_code, ''), github_comment_id, false_positive, created_at
+		FROM review_issues
+		WHERE github_comment_id = $1`
+
+	var issue model.ReviewIssue
+	err := r.Pool.QueryRow(ctx, query, commentID).Scan(
+		&issue.ID,
+		&issue.ReviewID,
+		&issue.FilePath,
+		&issue.LineNumber,
+		&issue.Severity,
+		&issue.Category,
+		&issue.Message,
+		&issue.Suggestion,
+		&issue.StartLine,
+		&issue.EndLine,
+		&issue.SuggestedCode,
+		&issue.GithubCommentID,
+		&issue.FalsePositive,
+		&issue.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get issue: %w", err)
+	}
+	return &issue, nil
+}
+
+// ListFixableIssues returns the findings of a review that carry suggested code
+// and were not dismissed as false positives
+func (r *IssueRepository) ListFixableIssues(ctx context.Context, reviewID int) ([]model.ReviewIssue, error) {
+	query := `
+		SELECT id, review_id, file_path, line_number, start_line, end_line, suggested_code
+		FROM review_issues
+		WHERE review_id = $1 AND COALESCE(suggested_code, '') <> '' AND start_line > 0 AND false_positive = FALSE
+		ORDER BY file_path, start_line`
+
+	rows, err := r.Pool.Query(ctx, query, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var issues []model.ReviewIssue
+	for rows.Next() {
+		var issue model.ReviewIssue
+		if err := rows.Scan(&issue.ID, &issue.ReviewID, &issue.FilePath, &issue.LineNumber, &issue.StartLine, &issue.EndLine, &issue.SuggestedCode); err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// MarkFalsePositive records that the developer convinced us the finding was wrong
+func (r *IssueRepository) MarkFalsePositive(ctx context.Context, issueID int) error {
+	_, err := r.Pool.Exec(ctx, `UPDATE review_issues SET false_positive = TRUE WHERE id = $1`, issueID)
+	return err
+}
+
+// AddThreadMessage appends a comment to the discussion under a finding
+func (r *IssueRepository) AddThreadMessage(ctx context.Context, msg *model.ThreadMessage) error {
+	query := `
+		INSERT INTO review_threads (issue_id, github_comment_id, author, body, from_bot)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (github_comment_id) DO NOTHING
+		RETURNING id, created_at`
+
+	err := r.Pool.QueryRow(ctx, query, msg.IssueID, msg.GithubCommentID, msg.Author, msg.Body, msg.FromBot).Scan(&msg.ID, &msg.CreatedAt)
+	if err != nil && err != pgx.ErrNoRows {
+		return fmt.Errorf("failed to insert thread message: %w", err)
+	}
+	return nil
+}
+
+// ListThreadMessages returns the discussion under a finding, oldest first
+func (r *IssueRepository) ListThreadMessages(ctx context.Context, issueID int) ([]model.ThreadMessage, error) {
+	query := `
+		SELECT id, issue_id, github_comment_id, author, body, from_bot, created_at
+		FROM review_threads
+		WHERE issue_id = $1
+		ORDER BY created_at, id`
+
+	rows, err := r.Pool.Query(ctx, query, issueID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []model.ThreadMessage
+	for rows.Next() {
+		var msg model.ThreadMessage
+		if err := rows.Scan(&msg.ID, &msg.IssueID, &msg.GithubCommentID, &msg.Author, &msg.Body, &msg.FromBot, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OutcomeRepository struct {
+	Pool *pgxpool.Pool
+}
+
+func NewOutcomeRepository(pool *pgxpool.Pool) *OutcomeRepository {
+	return &OutcomeRepository{Pool: pool}
+}
+
+// RecordMergedPR stores the final state of a PR together with the review
+// counts at merge time. A redelivered event overwrites the same row.
+func (r *OutcomeRepository) RecordMergedPR(ctx context.Context, o *model.PROutcome) error {
+	query := `
+		INSERT INTO pr_outcomes (repository_id, pr_number, merged_at, merge_commit_sha, head_sha,
+			additions, deletions, changed_files, reviews, findings, false_positives)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8,
+			(SELECT COUNT(*) FROM reviews WHERE repository_id = $1 AND pr_number = $2),
+			(SELECT COUNT(*) FROM review_issues i JOIN reviews r ON r.id = i.review_id
+				WHERE r.repository_id = $1 AND r.pr_number = $2),
+			(SELECT COUNT(*) FROM review_issues i JOIN reviews r ON r.id = i.review_id
+				WHERE r.repository_id = $1 AND r.pr_number = $2 AND i.false_positive)
+		ON CONFLICT (repository_id, pr_number)
+		DO UPDATE SET
+			merged_at = EXCLUDED.merged_at,
+			merge_commit_sha = EXCLUDED.merge_commit_sha,
+			head_sha = EXCLUDED.head_sha,
+			additions = EXCLUDED.additions,
+			deletions = EXCLUDED.deletions,
+			changed_files = EXCLUDED.changed_files,
+			reviews = EXCLUDED.reviews,
+			findings = EXCLUDED.findings,
+			false_positives = EXCLUDED.false_positives
+		RETURNING id, reviews, findings, false_positives`
+
+	return r.Pool.QueryRow(ctx, query,
+		o.RepositoryID, o.PRNumber, o.MergedAt, o.MergeCommitSHA, o.HeadSHA,
+		o.Additions, o.Deletions, o.ChangedFiles,
+	).Scan(&o.ID, &o.Reviews, &o.Findings, &o.FalsePositives)
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PolicyRepository struct {
+	Pool *pgxpool.Pool
+}
+
+func NewPolicyRepository(pool *pgxpool.Pool) *PolicyRepository {
+	return &PolicyRepository{Pool: pool}
+}
+
+// GetPolicy returns the policy stored for owner (repoID 0) or for one
+// repository, nil if there is none
+func (r *PolicyRepository) GetPolicy(ctx context.Context, owner string, repoID int) (*model.DataPolicy, error) {
+	query := `
+		SELECT id, owner, repository_id, COALESCE(never_send, ''), COALESCE(local_only, ''), updated_at
+		FROM data_policies
+		WHERE owner = $1 AND repository_id = $2`
+
+	var p model.DataPolicy
+	err := r.Pool.QueryRow(ctx, query, owner, repoID).Scan(&p.ID, &p.Owner, &p.RepositoryID, &p.NeverSend, &p.LocalOnly, &p.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get data policy: %w", err)
+	}
+	return &p, nil
+}
+
+// ListEffectivePolicies returns the organization policy and the repository
+// policy that both apply to a review
+func (r *PolicyRepository) ListEffectivePolicies(ctx context.Context, owner string, repoID int) ([]model.DataPolicy, error) {
+	query := `
+		SELECT id, owner, repository_id, COALESCE(never_send, ''), COALESCE(local_only, ''), updated_at
+		FROM data_policies
+		WHERE owner = $1 AND repository_id IN (0, $2)
+		ORDER BY repository_id`
+
+	rows, err := r.Pool.Query(ctx, query, owner, repoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []model.DataPolicy
+	for rows.Next() {
+		var p model.DataPolicy
+		if err := rows.Scan(&p.ID, &p.Owner, &p.RepositoryID, &p.NeverSend, &p.LocalOnly, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// UpsertPolicy creates or replaces the policy for owner/repository
+func (r *PolicyRepository) UpsertPolicy(ctx context.Context, p *model.DataPolicy) error {
+	query := `
+		INSERT INTO data_policies (owner, repository_id, never_send, local_only, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (owner, repository_id)
+		DO UPDATE SET
+			never_send = $3,
+			local_only = $4,
+			updated_at = NOW()
+		RETURNING id, updated_at`
+
+	return r.Pool.QueryRow(ctx, query, p.Owner, p.RepositoryID, p.NeverSend, p.LocalOnly).Scan(&p.ID, &p.UpdatedAt)
+}
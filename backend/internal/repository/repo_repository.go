@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -17,7 +18,7 @@ func NewRepoRepository(pool *pgxpool.Pool) *RepoRepository {
 	return &RepoRepository{Pool: pool}
 }
 
-func (r *RepoRepository) CreateRepository(ctx context.Context, userID int, name, owner string) (*model.Repository, error) {
+func (r *RepoRepository) CreateRepository(ctx context.Context, userID int, name, owner, provider, baseURL string) (*model.Repository, error) {
 	tx, err := r.Pool.Begin(ctx)
 	if err != nil {
 		return nil, err
@@ -29,11 +30,11 @@ func (r *RepoRepository) CreateRepository(ctx context.Context, userID int, name,
 	var createdAt time.Time
 	
 	repoQuery := `
-		INSERT INTO repositories (user_id, name, owner)
-		VALUES ($1, $2, $3)
+		INSERT INTO repositories (user_id, name, owner, provider, base_url)
+		VALUES (NULLIF($1, 0), $2, $3, $4, NULLIF($5, ''))
 		RETURNING id, created_at`
 	
-	err = tx.QueryRow(ctx, repoQuery, userID, name, owner).Scan(&repoID, &createdAt)
+	err = tx.QueryRow(ctx, repoQuery, userID, name, owner, provider, baseURL).Scan(&repoID, &createdAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert repo: %w", err)
 	}
@@ -58,13 +59,15 @@ func (r *RepoRepository) CreateRepository(ctx context.Context, userID int, name,
 		UserID:    userID,
 		Name:      name,
 		Owner:     owner,
+		Provider:  provider,
+		BaseURL:   baseURL,
 		CreatedAt: createdAt,
 	}, nil
 }
 
 // ListRepositories gets all repos for a specific user
 func (r *RepoRepository) ListRepositories(ctx context.Context, userID int) ([]model.Repository, error) {
-	rows, err := r.Pool.Query(ctx, "SELECT id, user_id, name, owner, created_at FROM repositories WHERE user_id = $1", userID)
+	rows, err := r.Pool.Query(ctx, "SELECT id, COALESCE(user_id, 0), name, owner, COALESCE(provider, 'github'), COALESCE(base_url, ''), created_at FROM repositories WHERE user_id = $1", userID)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +76,7 @@ func (r *RepoRepository) ListRepositories(ctx context.Context, userID int) ([]mo
 	var repos []model.Repository
 	for rows.Next() {
 		var repo model.Repository
-		if err := rows.Scan(&repo.ID, &repo.UserID, &repo.Name, &repo.Owner, &repo.CreatedAt); err != nil {
+		if err := rows.Scan(&repo.ID, &repo.UserID, &repo.Name, &repo.Owner, &repo.Provider, &repo.BaseURL, &repo.CreatedAt); err != nil {
 			return nil, err
 		}
 		repos = append(repos, repo)
@@ -83,18 +86,191 @@ func (r *RepoRepository) ListRepositories(ctx context.Context, userID int) ([]mo
 
 // GetRepositoryByID fetches a single repo
 func (r *RepoRepository) GetRepositoryByID(ctx context.Context, id int) (*model.Repository, error) {
-	query := `SELECT id, user_id, name, owner, created_at FROM repositories WHERE id = $1`
+	query := `SELECT id, COALESCE(user_id, 0), name, owner, COALESCE(provider, 'github'), COALESCE(base_url, ''), created_at FROM repositories WHERE id = $1`
 	row := r.Pool.QueryRow(ctx, query, id)
 
 	var repo model.Repository
-	err := row.Scan(&repo.ID, &repo.UserID, &repo.Name, &repo.Owner, &repo.CreatedAt)
+	err := row.Scan(&repo.ID, &repo.UserID, &repo.Name, &repo.Owner, &repo.Provider, &repo.BaseURL, &repo.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &repo, nil
 }
 
+// GetRepositoryByOwnerName resolves a GitHub "owner/name" to our record.
+// Returns nil if the repository was never registered.
+func (r *RepoRepository) GetRepositoryByOwnerName(ctx context.Context, owner, name string) (*model.Repository, error) {
+	query := `SELECT id, COALESCE(user_id, 0), name, owner, COALESCE(provider, 'github'), COALESCE(base_url, ''), created_at FROM repositories WHERE owner = $1 AND name = $2 ORDER BY id LIMIT 1`
+	row := r.Pool.QueryRow(ctx, query, owner, name)
 
+	var repo model.Repository
+	err := row.Scan(&repo.ID, &repo.UserID, &repo.Name, &repo.Owner, &repo.Provider, &repo.BaseURL, &repo.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// FindRepository resolves owner/name on a code host to our record. When the
+// same path is registered on several instances, the one whose base URL
+// webURL (the repository's page, from the webhook) lives under wins.
+// Returns nil if the repository was never registered.
+func (r *RepoRepository) FindRepository(ctx context.Context, provider, owner, name, webURL string) (*model.Repository, error) {
+	query := `
+		SELECT id, COALESCE(user_id, 0), name, owner, COALESCE(provider, 'github'), COALESCE(base_url, ''), created_at
+		FROM repositories
+		WHERE owner = $1 AND name = $2 AND COALESCE(provider, 'github') = $3
+		ORDER BY (COALESCE(base_url, '') <> '' AND position(base_url || '/' in $4) = 1) DESC, id
+		LIMIT 1`
+	row := r.Pool.QueryRow(ctx, query, owner, name, provider, webURL)
+
+	var repo model.Repository
+	err := row.Scan(&repo.ID, &repo.UserID, &repo.Name, &repo.Owner, &repo.Provider, &repo.BaseURL, &repo.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// RegisterInstalledRepository links owner/name to an app installation. Only
+// registrations without a user or made by the app itself are linked; when a
+// user registered the repository by hand it is left alone. If there is no
+// registration, the app creates one under userID, the installer (0 if they
+// never logged in to the dashboard).
+func (r *RepoRepository) RegisterInstalledRepository(ctx context.Context, installationID int64, userID int, owner, name string) error {
+	query := `
+		UPDATE repositories SET installation_id = $1
+		WHERE owner = $2 AND name = $3 AND COALESCE(provider, 'github') = 'github'
+			AND (user_id IS NULL OR added_by_installation)
+			AND (installation_id IS NULL OR installation_id = $1)`
+	tag, err := r.Pool.Exec(ctx, query, installationID, owner, name)
+	if err != nil {
+		return fmt.Errorf("failed to link repository: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var registered bool
+	err = r.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM repositories WHERE owner = $1 AND name = $2 AND COALESCE(provider, 'github') = 'github')`, owner, name).Scan(&registered)
+	if err != nil {
+		return fmt.Errorf("failed to look up repository: %w", err)
+	}
+	if registered {
+		return nil
+	}
+
+	repo, err := r.CreateRepository(ctx, userID, name, owner, "github", "")
+	if err != nil {
+		return err
+	}
+	if _, err := r.Pool.Exec(ctx, `UPDATE repositories SET installation_id = $1, added_by_installation = TRUE WHERE id = $2`, installationID, repo.ID); err != nil {
+		return fmt.Errorf("failed to link repository: %w", err)
+	}
+	return nil
+}
+
+// RemoveInstalledRepository undoes RegisterInstalledRepository for owner/name
+func (r *RepoRepository) RemoveInstalledRepository(ctx context.Context, installationID int64, owner, name string) error {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := unlinkInstallation(ctx, tx, installationID, owner, name); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// unlinkInstallation deletes the repositories the installation created,
+// with their configuration and reviews, and detaches it from the others.
+// Empty owner and name cover every repository of the installation.
+func unlinkInstallation(ctx context.Context, tx pgx.Tx, installationID int64, owner, name string) error {
+	created := `SELECT id FROM repositories
+		WHERE installation_id = $1 AND added_by_installation
+			AND ($2 = '' OR owner = $2) AND ($3 = '' OR name = $3)`
+
+	for _, table := range []string{"configurations", "reviews", "data_policies"} {
+		query := fmt.Sprintf("DELETE FROM %s WHERE repository_id IN (%s)", table, created)
+		if _, err := tx.Exec(ctx, query, installationID, owner, name); err != nil {
+			return fmt.Errorf("failed to delete %s of installation repositories: %w", table, err)
+		}
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM repositories WHERE id IN ("+created+")", installationID, owner, name); err != nil {
+		return fmt.Errorf("failed to delete installation repositories: %w", err)
+	}
+
+	query := `UPDATE repositories SET installation_id = NULL
+		WHERE installation_id = $1 AND ($2 = '' OR owner = $2) AND ($3 = '' OR name = $3)`
+	if _, err := tx.Exec(ctx, query, installationID, owner, name); err != nil {
+		return fmt.Errorf("failed to unlink installation repositories: %w", err)
+	}
+	return nil
+}
+
+// GetWebhook returns the hook and sealed secrets of a repository
+func (r *RepoRepository) GetWebhook(ctx context.Context, repoID int) (*model.RepoWebhook, error) {
+	query := `
+		SELECT id, COALESCE(webhook_id, 0), COALESCE(webhook_secret, ''), COALESCE(previous_webhook_secret, ''), previous_secret_expires_at
+		FROM repositories WHERE id = $1`
+
+	var w model.RepoWebhook
+	err := r.Pool.QueryRow(ctx, query, repoID).Scan(&w.RepositoryID, &w.HookID, &w.Secret, &w.PreviousSecret, &w.PreviousExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return &w, nil
+}
+
+// ListWebhooksByOwnerName returns the hooks of every registration of
+// "owner/name" on a code host, for verifying a delivery before we know which
+// one it is for
+func (r *RepoRepository) ListWebhooksByOwnerName(ctx context.Context, provider, owner, name string) ([]model.RepoWebhook, error) {
+	query := `
+		SELECT id, COALESCE(webhook_id, 0), COALESCE(webhook_secret, ''), COALESCE(previous_webhook_secret, ''), previous_secret_expires_at
+		FROM repositories WHERE owner = $1 AND name = $2 AND COALESCE(provider, 'github') = $3`
+
+	rows, err := r.Pool.Query(ctx, query, owner, name, provider)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []model.RepoWebhook
+	for rows.Next() {
+		var w model.RepoWebhook
+		if err := rows.Scan(&w.RepositoryID, &w.HookID, &w.Secret, &w.PreviousSecret, &w.PreviousExpiresAt); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, w)
+	}
+	return hooks, rows.Err()
+}
+
+// SaveWebhook stores the hook ID and sealed secrets of a repository
+func (r *RepoRepository) SaveWebhook(ctx context.Context, w *model.RepoWebhook) error {
+	query := `
+		UPDATE repositories SET
+			webhook_id = NULLIF($2, 0),
+			webhook_secret = NULLIF($3, ''),
+			previous_webhook_secret = NULLIF($4, ''),
+			previous_secret_expires_at = $5
+		WHERE id = $1`
+
+	_, err := r.Pool.Exec(ctx, query, w.RepositoryID, w.HookID, w.Secret, w.PreviousSecret, w.PreviousExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook: %w", err)
+	}
+	return nil
+}
 
 func (r *RepoRepository) DeleteRepository(ctx context.Context, repoID, userID int) error {
 	// 1. Start a Transaction (To ensure both delete, or neither deletes)
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
 )
@@ -16,12 +17,23 @@ func NewReviewRepository(pool *pgxpool.Pool) *ReviewRepository {
 }
 
 // CreateReview starts a new review entry (status: pending)
-func (r *ReviewRepository) CreateReview(ctx context.Context, repoID int, prNumber int) (int, error) {
+func (r *ReviewRepository) CreateReview(ctx context.Context, repoID int, prNumber int, commitSHA string) (int, error) {
 	var id int
 	query := `INSERT INTO reviews (repository_id, pr_number, status, commit_sha, created_at) 
-	          VALUES ($1, $2, 'pending', 'dummy-sha-123', NOW()) RETURNING id`
+	          VALUES ($1, $2, 'pending', $3, NOW()) RETURNING id`
 	
-	err := r.Pool.QueryRow(ctx, query, repoID, prNumber).Scan(&id)
+	err := r.Pool.QueryRow(ctx, query, repoID, prNumber, commitSHA).Scan(&id)
+	return id, err
+}
+
+// CreateCommitRangeReview starts the review of commits pushed to a branch,
+// which has a commit range instead of a PR number
+func (r *ReviewRepository) CreateCommitRangeReview(ctx context.Context, repoID int, commitRange, headSHA string) (int, error) {
+	var id int
+	query := `INSERT INTO reviews (repository_id, target_type, commit_range, status, commit_sha, created_at)
+	          VALUES ($1, 'commit_range', $2, 'pending', $3, NOW()) RETURNING id`
+
+	err := r.Pool.QueryRow(ctx, query, repoID, commitRange, headSHA).Scan(&id)
 	return id, err
 }
 
@@ -34,7 +46,8 @@ func (r *ReviewRepository) UpdateReviewResult(ctx context.Context, id int, conte
 
 // GetReviewsByRepoID fetches all reviews for a specific project
 func (r *ReviewRepository) GetReviewsByRepoID(ctx context.Context, repoID int) ([]model.Review, error) {
-	query := `SELECT id, repository_id, pr_number, status, COALESCE(content, ''), created_at FROM reviews WHERE repository_id = $1 ORDER BY created_at DESC`
+	query := `SELECT id, repository_id, COALESCE(target_type, 'pull_request'), COALESCE(pr_number, 0), COALESCE(commit_range, ''), status, COALESCE(content, ''), created_at
+	          FROM reviews WHERE repository_id = $1 ORDER BY created_at DESC`
 	
 	rows, err := r.Pool.Query(ctx, query, repoID)
 	if err != nil {
@@ -45,10 +58,26 @@ func (r *ReviewRepository) GetReviewsByRepoID(ctx context.Context, repoID int) (
 	var reviews []model.Review
 	for rows.Next() {
 		var rev model.Review
-		if err := rows.Scan(&rev.ID, &rev.RepositoryID, &rev.PRNumber, &rev.Status, &rev.Content, &rev.CreatedAt); err != nil {
+		if err := rows.Scan(&rev.ID, &rev.RepositoryID, &rev.TargetType, &rev.PRNumber, &rev.CommitRange, &rev.Status, &rev.Content, &rev.CreatedAt); err != nil {
 			return nil, err
 		}
 		reviews = append(reviews, rev)
 	}
 	return reviews, nil
-}
\ No newline at end of file
+}
+
+// GetLatestReview returns the most recent review of a PR, or nil if there is none
+func (r *ReviewRepository) GetLatestReview(ctx context.Context, repoID int, prNumber int) (*model.Review, error) {
+	query := `SELECT id, repository_id, pr_number, COALESCE(commit_sha, ''), status, COALESCE(content, ''), created_at
+	          FROM reviews WHERE repository_id = $1 AND pr_number = $2 ORDER BY id DESC LIMIT 1`
+
+	rev := model.Review{TargetType: model.ReviewTargetPullRequest}
+	err := r.Pool.QueryRow(ctx, query, repoID, prNumber).Scan(&rev.ID, &rev.RepositoryID, &rev.PRNumber, &rev.CommitSHA, &rev.Status, &rev.Content, &rev.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rev, nil
+}
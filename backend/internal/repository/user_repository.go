@@ -37,6 +37,19 @@ func (r *UserRepository) UpsertUser(ctx context.Context, githubID int64, usernam
 	return id, nil
 }
 
+// GetUserIDByGithubID returns our ID for a GitHub account, 0 if they never logged in
+func (r *UserRepository) GetUserIDByGithubID(ctx context.Context, githubID int64) (int, error) {
+	var id int
+	err := r.Pool.QueryRow(ctx, `SELECT id FROM users WHERE github_id = $1`, githubID).Scan(&id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
 // GetUserByID fetches a user from the 'users' table
 func (r *UserRepository) GetUserByID(ctx context.Context, id int) (*model.User, error) {
 
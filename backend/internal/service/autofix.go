@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Fix is a suggested replacement of lines StartLine..EndLine (1-based, inclusive) in Path
+type Fix struct {
+	IssueID   int
+	Path      string
+	StartLine int
+	EndLine   int
+	Code      string
+}
+
+// SelectFixes splits fixes into those that can be applied together and those
+// that touch overlapping lines of the same file. Overlapping fixes are all
+// left out: there is no way to know which one the author would prefer.
+func SelectFixes(fixes []Fix) (accepted []Fix, conflicting []Fix) {
+	sorted := make([]Fix, len(fixes))
+	copy(sorted, fixes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Path != sorted[j].Path {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].StartLine < sorted[j].StartLine
+	})
+
+	conflict := make([]bool, len(sorted))
+	for i := range sorted {
+		for j := i + 1; j < len(sorted) && sorted[j].Path == sorted[i].Path; j++ {
+			if sorted[j].StartLine > sorted[i].EndLine {
+				break
+			}
+			conflict[i], conflict[j] = true, true
+		}
+	}
+
+	for i, fix := range sorted {
+		if conflict[i] {
+			conflicting = append(conflicting, fix)
+		} else {
+			accepted = append(accepted, fix)
+		}
+	}
+	return accepted, conflicting
+}
+
+// ApplyFixes rewrites content with non-overlapping fixes for a single file.
+// Fixes whose range falls outside the file are returned as failed.
+func ApplyFixes(content string, fixes []Fix) (string, []Fix, []Fix) {
+	lines := strings.Split(content, "\n")
+
+	ordered := make([]Fix, len(fixes))
+	copy(ordered, fixes)
+	// Bottom-up, so earlier replacements don't shift the later ranges
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].StartLine > ordered[j].StartLine })
+
+	var applied, failed []Fix
+	for _, fix := range ordered {
+		if fix.StartLine < 1 || fix.EndLine < fix.StartLine || fix.EndLine > len(lines) {
+			failed = append(failed, fix)
+			continue
+		}
+		replacement := strings.Split(strings.TrimRight(fix.Code, "\n"), "\n")
+
+		updated := make([]string, 0, len(lines)-(fix.EndLine-fix.StartLine+1)+len(replacement))
+		updated = append(updated, lines[:fix.StartLine-1]...)
+		updated = append(updated, replacement...)
+		updated = append(updated, lines[fix.EndLine:]...)
+		lines = updated
+		applied = append(applied, fix)
+	}
+	return strings.Join(lines, "\n"), applied, failed
+}
+
+func (f Fix) String() string {
+	if f.StartLine == f.EndLine {
+		return fmt.Sprintf("%s:%d", f.Path, f.StartLine)
+	}
+	return fmt.Sprintf("%s:%d-%d", f.Path, f.StartLine, f.EndLine)
+}
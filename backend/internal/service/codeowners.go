@@ -0,0 +1,75 @@
+package service
+
+import (
+	"strings"
+)
+
+// CodeOwnersPaths are the locations GitHub reads CODEOWNERS from, in order
+var CodeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// OwnerRule assigns owners to the paths matching Pattern
+type OwnerRule struct {
+	Pattern string
+	Owners  []string // "@user", "@org/team" or an email address
+}
+
+// CodeOwners is a parsed CODEOWNERS file. Later rules take precedence.
+type CodeOwners struct {
+	Rules []OwnerRule
+}
+
+// ParseCodeOwners reads the CODEOWNERS syntax, which is also the syntax of
+// the ownership_rules setting
+func ParseCodeOwners(content string) *CodeOwners {
+	co := &CodeOwners{}
+	for _, line := range splitLines(content) {
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || ValidateGlobs(fields[0]) != nil {
+			continue
+		}
+		// A pattern without owners un-assigns the paths, so it is kept
+		co.Rules = append(co.Rules, OwnerRule{Pattern: fields[0], Owners: fields[1:]})
+	}
+	return co
+}
+
+// OwnersOf returns the owners of path from the last matching rule
+func (co *CodeOwners) OwnersOf(path string) []string {
+	if co == nil {
+		return nil
+	}
+	for i := len(co.Rules) - 1; i >= 0; i-- {
+		if MatchGlob(co.Rules[i].Pattern, path) {
+			return co.Rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// ReviewersFor collects the users and team slugs that own any of paths.
+// Email owners can't be requested through the API and are left out, and so
+// is the PR author, whom GitHub refuses as a reviewer.
+func (co *CodeOwners) ReviewersFor(paths []string, author string) (users, teams []string) {
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		for _, owner := range co.OwnersOf(p) {
+			if !strings.HasPrefix(owner, "@") || seen[owner] {
+				continue
+			}
+			seen[owner] = true
+			name := strings.TrimPrefix(owner, "@")
+			if strings.Contains(name, "@") {
+				continue
+			}
+			if i := strings.Index(name, "/"); i >= 0 {
+				teams = append(teams, name[i+1:])
+			} else if !strings.EqualFold(name, author) {
+				users = append(users, name)
+			}
+		}
+	}
+	return users, teams
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ModelProvider describes where a model runs, so the data policy can decide
+// which files it may see
+type ModelProvider interface {
+	ProviderName() string
+	IsLocal() bool
+}
+
+// DataPolicy lists the paths that must not leave the network. NeverSend
+// paths are withheld from every provider, LocalOnly paths from every provider
+// that is not self-hosted.
+type DataPolicy struct {
+	NeverSend []string
+	LocalOnly []string
+}
+
+// WithheldFile is a changed file the policy kept away from the model
+type WithheldFile struct {
+	Path   string
+	Reason string
+}
+
+// NewDataPolicy parses newline-separated globs. "**" matches any number of
+// directories, and a pattern without a slash matches the file name at any depth.
+func NewDataPolicy(neverSend, localOnly string) (*DataPolicy, error) {
+	if err := ValidateGlobs(neverSend + "\n" + localOnly); err != nil {
+		return nil, err
+	}
+	return &DataPolicy{NeverSend: splitLines(neverSend), LocalOnly: splitLines(localOnly)}, nil
+}
+
+// Merge adds the rules of other, so an organization policy and a repository
+// policy both apply
+func (p *DataPolicy) Merge(other *DataPolicy) {
+	if other == nil {
+		return
+	}
+	p.NeverSend = append(p.NeverSend, other.NeverSend...)
+	p.LocalOnly = append(p.LocalOnly, other.LocalOnly...)
+}
+
+// Check returns why path may not be sent to provider, or "" if it may
+func (p *DataPolicy) Check(filePath string, provider ModelProvider) string {
+	if p == nil {
+		return ""
+	}
+	for _, pattern := range p.NeverSend {
+		if MatchGlob(pattern, filePath) {
+			return fmt.Sprintf("never sent to a model (`%s`)", pattern)
+		}
+	}
+	if !provider.IsLocal() {
+		for _, pattern := range p.LocalOnly {
+			if MatchGlob(pattern, filePath) {
+				return fmt.Sprintf("restricted to local models (`%s`), %s is external", pattern, provider.ProviderName())
+			}
+		}
+	}
+	return ""
+}
+
+// Filter splits files into the ones provider may see and the withheld ones
+func (p *DataPolicy) Filter(files []FileChange, provider ModelProvider) ([]FileChange, []WithheldFile) {
+	var allowed []FileChange
+	var withheld []WithheldFile
+	for _, f := range files {
+		if reason := p.Check(f.Path, provider); reason != "" {
+			withheld = append(withheld, WithheldFile{Path: f.Path, Reason: reason})
+			continue
+		}
+		allowed = append(allowed, f)
+	}
+	return allowed, withheld
+}
+
+// FilterDiff removes the withheld files from a raw unified diff
+func (p *DataPolicy) FilterDiff(rawDiff string, provider ModelProvider) (string, []WithheldFile) {
+	var withheld []WithheldFile
+	filtered := filterDiff(rawDiff, func(filePath string) bool {
+		if reason := p.Check(filePath, provider); reason != "" {
+			withheld = append(withheld, WithheldFile{Path: filePath, Reason: reason})
+			return false
+		}
+		return true
+	})
+	return filtered, withheld
+}
+
+// filterDiff keeps the file diffs whose path keep accepts
+func filterDiff(rawDiff string, keep func(path string) bool) string {
+	var sb strings.Builder
+	for _, rawFile := range strings.Split(rawDiff, "diff --git ") {
+		if strings.TrimSpace(rawFile) == "" || !keep(extractFilePath(rawFile)) {
+			continue
+		}
+		sb.WriteString("diff --git ")
+		sb.WriteString(rawFile)
+	}
+	return sb.String()
+}
+
+// Fetcher wraps fetch so that context builders can't pull a withheld file
+// into the prompt, e.g. a sibling file of the same Go package
+func (p *DataPolicy) Fetcher(fetch FileFetcher, provider ModelProvider) FileFetcher {
+	return func(filePath string) (string, error) {
+		if reason := p.Check(filePath, provider); reason != "" {
+			return "", fmt.Errorf("%s: %s", filePath, reason)
+		}
+		return fetch(filePath)
+	}
+}
+
+// MatchGlob reports whether filePath matches a policy pattern
+func MatchGlob(pattern, filePath string) bool {
+	re, err := globRegexp(pattern)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(strings.TrimPrefix(path.Clean(filePath), "/"))
+}
+
+// MatchBranch reports whether a branch matches one of the newline-separated
+// globs. Patterns match the whole branch name, so "main" is not "feature/main".
+func MatchBranch(patterns, branch string) bool {
+	for _, pattern := range splitLines(patterns) {
+		if MatchGlob("/"+strings.TrimPrefix(pattern, "/"), branch) {
+			return true
+		}
+	}
+	return false
+}
+
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimSpace(pattern)
+	// As in .gitignore, a leading slash anchors the pattern at the root
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !anchored && !strings.Contains(pattern, "/") {
+		sb.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// "dir/" or "dir" also covers everything below it
+	sb.WriteString("(?:/.*)?$")
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,524 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DependencyChange is one package added, removed or moved to another version
+type DependencyChange struct {
+	Ecosystem string // "go" or "npm"
+	Name      string
+	From      string // Empty when added
+	To        string // Empty when removed
+	Direct    bool   // Declared in go.mod without "// indirect" or in package.json
+	License   string // Only known for package-lock.json entries
+	File      string
+	Line      int // New-file line of the change, 0 for removals
+
+	MajorJump         bool
+	DisallowedLicense bool
+	Advisories        []Advisory
+}
+
+// Kind is "added", "removed", "upgraded" or "downgraded"
+func (d DependencyChange) Kind() string {
+	switch {
+	case d.From == "":
+		return "added"
+	case d.To == "":
+		return "removed"
+	case compareVersions(d.To, d.From) < 0:
+		return "downgraded"
+	}
+	return "upgraded"
+}
+
+// Flagged reports whether the change needs a reviewer's attention
+func (d DependencyChange) Flagged() bool {
+	return d.MajorJump || d.DisallowedLicense || len(d.Advisories) > 0
+}
+
+// Advisory is one entry of the local vulnerability database. A version is
+// affected when it is >= Introduced (or Introduced is empty) and < Fixed (or
+// Fixed is empty).
+type Advisory struct {
+	ID         string `json:"id"`
+	Ecosystem  string `json:"ecosystem"`
+	Package    string `json:"package"`
+	Introduced string `json:"introduced"`
+	Fixed      string `json:"fixed"`
+	Severity   string `json:"severity"`
+	Summary    string `json:"summary"`
+}
+
+// Affects reports whether version is in the advisory's vulnerable range
+func (a Advisory) Affects(version string) bool {
+	if version == "" {
+		return false
+	}
+	if a.Introduced != "" && a.Introduced != "0" && compareVersions(version, a.Introduced) < 0 {
+		return false
+	}
+	return a.Fixed == "" || compareVersions(version, a.Fixed) < 0
+}
+
+// LoadAdvisories reads the advisory database, a JSON array of Advisory. The
+// path comes from ADVISORY_DB_PATH; without it no advisories are checked.
+func LoadAdvisories() ([]Advisory, error) {
+	dbPath := os.Getenv("ADVISORY_DB_PATH")
+	if dbPath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read advisory database: %w", err)
+	}
+	var advisories []Advisory
+	if err := json.Unmarshal(data, &advisories); err != nil {
+		return nil, fmt.Errorf("failed to parse advisory database: %w", err)
+	}
+	return advisories, nil
+}
+
+// AnalyzeDependencies extracts dependency changes from the manifests and
+// lockfiles in a raw diff and checks them against the advisories and the
+// license allowlist (SPDX IDs separated by commas or newlines, empty = any).
+func AnalyzeDependencies(rawDiff string, advisories []Advisory, licenseAllowlist string) []DependencyChange {
+	var direct, locked []DependencyChange
+	for _, fd := range splitFileDiffs(rawDiff) {
+		switch path.Base(fd.path) {
+		case "go.mod":
+			direct = append(direct, parseGoMod(fd)...)
+		case "go.sum":
+			locked = append(locked, parseGoSum(fd)...)
+		case "package.json":
+			direct = append(direct, parsePackageJSON(fd)...)
+		case "package-lock.json":
+			locked = append(locked, parsePackageLock(fd)...)
+		case "yarn.lock":
+			locked = append(locked, parseYarnLock(fd)...)
+		}
+	}
+
+	changes := mergeDependencyChanges(direct, locked)
+
+	allowed := make(map[string]bool)
+	for _, id := range strings.FieldsFunc(licenseAllowlist, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if id = strings.TrimSpace(id); id != "" {
+			allowed[strings.ToLower(id)] = true
+		}
+	}
+
+	for i := range changes {
+		c := &changes[i]
+		c.MajorJump = c.From != "" && c.To != "" && isMajorJump(c.From, c.To)
+		if len(allowed) > 0 && c.License != "" && c.To != "" {
+			c.DisallowedLicense = !licenseAllowed(c.License, allowed)
+		}
+		for _, a := range advisories {
+			if a.Ecosystem == c.Ecosystem && a.Package == c.Name && a.Affects(c.To) {
+				c.Advisories = append(c.Advisories, a)
+			}
+		}
+	}
+	return changes
+}
+
+// mergeDependencyChanges combines manifest changes with the resolved versions
+// from lockfiles. Lockfile entries for packages not in a manifest change are
+// transitive.
+func mergeDependencyChanges(direct, locked []DependencyChange) []DependencyChange {
+	key := func(d DependencyChange) string { return d.Ecosystem + ":" + d.Name }
+
+	byName := make(map[string]int)
+	var out []DependencyChange
+	for _, d := range direct {
+		byName[key(d)] = len(out)
+		out = append(out, d)
+	}
+
+	for _, l := range locked {
+		if i, ok := byName[key(l)]; ok {
+			// package.json only has ranges, the lockfile has what gets installed
+			if out[i].Ecosystem == "npm" {
+				if l.From != "" {
+					out[i].From = l.From
+				}
+				if l.To != "" {
+					out[i].To = l.To
+				}
+			}
+			if out[i].License == "" {
+				out[i].License = l.License
+			}
+			continue
+		}
+		byName[key(l)] = len(out)
+		out = append(out, l)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Direct != out[j].Direct {
+			return out[i].Direct
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// diffLine is one line of a file diff with its sign and new-file line number
+type diffLine struct {
+	sign byte // '+', '-' or ' '
+	text string
+	line int // New-file line number, 0 for removed lines
+}
+
+type fileDiff struct {
+	path  string
+	lines []diffLine
+}
+
+func splitFileDiffs(rawDiff string) []fileDiff {
+	var out []fileDiff
+	for _, rawFile := range strings.Split(rawDiff, "diff --git ") {
+		filePath := extractFilePath(rawFile)
+		if filePath == "" {
+			continue
+		}
+
+		fd := fileDiff{path: filePath}
+		newLine, inHunk := 0, false
+		for _, l := range strings.Split(rawFile, "\n") {
+			if strings.HasPrefix(l, "@@") {
+				start, _, ok := parseHunkHeader(l)
+				newLine, inHunk = start, ok
+				continue
+			}
+			if !inHunk || l == "" {
+				continue
+			}
+			switch l[0] {
+			case '+':
+				fd.lines = append(fd.lines, diffLine{sign: '+', text: l[1:], line: newLine})
+				newLine++
+			case '-':
+				fd.lines = append(fd.lines, diffLine{sign: '-', text: l[1:]})
+			case ' ':
+				fd.lines = append(fd.lines, diffLine{sign: ' ', text: l[1:], line: newLine})
+				newLine++
+			}
+		}
+		out = append(out, fd)
+	}
+	return out
+}
+
+// versionEntry is one side of a package seen in a diff
+type versionEntry struct {
+	version  string
+	line     int
+	indirect bool
+	license  string
+}
+
+// pairChanges matches removed and added versions of the same package
+func pairChanges(ecosystem, file string, removed, added map[string]versionEntry, direct bool) []DependencyChange {
+	var out []DependencyChange
+	for name, a := range added {
+		r, ok := removed[name]
+		if ok && r.version == a.version {
+			continue
+		}
+		out = append(out, DependencyChange{
+			Ecosystem: ecosystem, Name: name, From: r.version, To: a.version,
+			Direct: direct && !a.indirect, License: a.license, File: file, Line: a.line,
+		})
+	}
+	for name, r := range removed {
+		if _, ok := added[name]; ok {
+			continue
+		}
+		out = append(out, DependencyChange{
+			Ecosystem: ecosystem, Name: name, From: r.version,
+			Direct: direct && !r.indirect, License: r.license, File: file,
+		})
+	}
+	return out
+}
+
+var goRequireLine = regexp.MustCompile(`^\s*(?:require\s+)?([^\s()]+)\s+(v[0-9][^\s]*)(\s*//\s*indirect)?\s*$`)
+
+func parseGoMod(fd fileDiff) []DependencyChange {
+	removed := make(map[string]versionEntry)
+	added := make(map[string]versionEntry)
+	for _, l := range fd.lines {
+		if l.sign == ' ' {
+			continue
+		}
+		m := goRequireLine.FindStringSubmatch(l.text)
+		if m == nil || m[1] == "module" || m[1] == "go" || m[1] == "toolchain" {
+			continue
+		}
+		entry := versionEntry{version: m[2], line: l.line, indirect: m[3] != ""}
+		if l.sign == '+' {
+			added[m[1]] = entry
+		} else {
+			removed[m[1]] = entry
+		}
+	}
+	return pairChanges("go", fd.path, removed, added, true)
+}
+
+// parseGoSum reports modules that appear in or disappear from go.sum, which
+// catches transitive modules that never show up in go.mod
+func parseGoSum(fd fileDiff) []DependencyChange {
+	removed := make(map[string]versionEntry)
+	added := make(map[string]versionEntry)
+	for _, l := range fd.lines {
+		fields := strings.Fields(l.text)
+		if l.sign == ' ' || len(fields) != 3 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		entry := versionEntry{version: fields[1], line: l.line, indirect: true}
+		if l.sign == '+' {
+			added[fields[0]] = entry
+		} else {
+			removed[fields[0]] = entry
+		}
+	}
+	return pairChanges("go", fd.path, removed, added, false)
+}
+
+var (
+	jsonSectionLine = regexp.MustCompile(`^\s*"([^"]+)"\s*:\s*\{\s*$`)
+	jsonStringField = regexp.MustCompile(`^\s*"([^"]+)"\s*:\s*"([^"]*)"\s*,?\s*$`)
+	npmVersionRange = regexp.MustCompile(`^(?:[\^~]|[<>]=?|=)?\s*v?\d+(?:\.[\dxX*]+){0,2}|^(?:npm|workspace|file|link|git\+|github):`)
+)
+
+var packageJSONSections = map[string]bool{
+	"dependencies": true, "devDependencies": true, "peerDependencies": true, "optionalDependencies": true,
+}
+
+// parsePackageJSON reads dependency entries from the diff. When the hunk shows
+// which object a line is in, entries outside the dependency sections are
+// skipped; otherwise only values that look like version ranges are used.
+func parsePackageJSON(fd fileDiff) []DependencyChange {
+	removed := make(map[string]versionEntry)
+	added := make(map[string]versionEntry)
+	section := ""
+	for _, l := range fd.lines {
+		if m := jsonSectionLine.FindStringSubmatch(l.text); m != nil {
+			section = m[1]
+			continue
+		}
+		if strings.TrimSpace(l.text) == "}" || strings.TrimSpace(l.text) == "}," {
+			section = ""
+			continue
+		}
+		if l.sign == ' ' || (section != "" && !packageJSONSections[section]) {
+			continue
+		}
+		m := jsonStringField.FindStringSubmatch(l.text)
+		if m == nil || !npmVersionRange.MatchString(m[2]) {
+			continue
+		}
+		if section == "" && (m[1] == "version" || m[1] == "node" || m[1] == "npm") {
+			continue
+		}
+		entry := versionEntry{version: m[2], line: l.line}
+		if l.sign == '+' {
+			added[m[1]] = entry
+		} else {
+			removed[m[1]] = entry
+		}
+	}
+	return pairChanges("npm", fd.path, removed, added, true)
+}
+
+// lockfileKeys are objects inside a package-lock.json entry, not packages
+var lockfileKeys = map[string]bool{
+	"packages": true, "dependencies": true, "devDependencies": true, "peerDependencies": true,
+	"optionalDependencies": true, "peerDependenciesMeta": true, "requires": true,
+	"engines": true, "bin": true, "funding": true, "os": true, "cpu": true,
+}
+
+// parsePackageLock follows the "node_modules/<name>": { ... } blocks of a
+// lockfile v2/v3 and the version and license lines changed inside them
+func parsePackageLock(fd fileDiff) []DependencyChange {
+	removed := make(map[string]versionEntry)
+	added := make(map[string]versionEntry)
+	current := ""
+	for _, l := range fd.lines {
+		if m := jsonSectionLine.FindStringSubmatch(l.text); m != nil {
+			if i := strings.LastIndex(m[1], "node_modules/"); i >= 0 {
+				current = m[1][i+len("node_modules/"):]
+			} else if m[1] != "" && !lockfileKeys[m[1]] {
+				// Lockfile v1 nests packages by name
+				current = m[1]
+			}
+			continue
+		}
+		if current == "" || l.sign == ' ' {
+			continue
+		}
+		m := jsonStringField.FindStringSubmatch(l.text)
+		if m == nil {
+			continue
+		}
+		target := added
+		if l.sign == '-' {
+			target = removed
+		}
+		entry := target[current]
+		switch m[1] {
+		case "version":
+			entry.version = m[2]
+			if l.sign == '+' {
+				entry.line = l.line
+			}
+		case "license":
+			entry.license = m[2]
+		default:
+			continue
+		}
+		target[current] = entry
+	}
+	dropUnversioned(removed)
+	dropUnversioned(added)
+	return pairChanges("npm", fd.path, removed, added, false)
+}
+
+var yarnVersionLine = regexp.MustCompile(`^\s+version:?\s+"?([^"\s]+)"?\s*$`)
+
+// parseYarnLock handles both the classic and the berry format: an unindented
+// `"name@range", name@range:` header followed by an indented version line
+func parseYarnLock(fd fileDiff) []DependencyChange {
+	removed := make(map[string]versionEntry)
+	added := make(map[string]versionEntry)
+	current := ""
+	for _, l := range fd.lines {
+		if l.text != "" && l.text[0] != ' ' && strings.HasSuffix(strings.TrimSpace(l.text), ":") {
+			current = yarnPackageName(l.text)
+			continue
+		}
+		if current == "" || l.sign == ' ' {
+			continue
+		}
+		m := yarnVersionLine.FindStringSubmatch(l.text)
+		if m == nil {
+			continue
+		}
+		entry := versionEntry{version: m[1], line: l.line}
+		if l.sign == '+' {
+			added[current] = entry
+		} else {
+			removed[current] = entry
+		}
+	}
+	return pairChanges("npm", fd.path, removed, added, false)
+}
+
+func yarnPackageName(header string) string {
+	spec := strings.TrimSpace(strings.SplitN(strings.TrimSuffix(strings.TrimSpace(header), ":"), ",", 2)[0])
+	spec = strings.Trim(spec, `"`)
+	// The "@" of a scoped package is not the version separator
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		return spec[:i]
+	}
+	return ""
+}
+
+func dropUnversioned(entries map[string]versionEntry) {
+	for name, e := range entries {
+		if e.version == "" {
+			delete(entries, name)
+		}
+	}
+}
+
+// licenseAllowed accepts an SPDX expression like "(MIT OR Apache-2.0)" if any
+// alternative is allowed
+func licenseAllowed(license string, allowed map[string]bool) bool {
+	expr := strings.NewReplacer("(", " ", ")", " ").Replace(license)
+	for _, id := range strings.Fields(expr) {
+		if strings.EqualFold(id, "OR") || strings.EqualFold(id, "AND") {
+			continue
+		}
+		if allowed[strings.ToLower(id)] {
+			return true
+		}
+	}
+	return false
+}
+
+// isMajorJump treats a minor bump of a 0.x version as major, as semver does
+func isMajorJump(from, to string) bool {
+	a, okA := parseSemver(from)
+	b, okB := parseSemver(to)
+	if !okA || !okB {
+		return false
+	}
+	if a[0] != b[0] {
+		return b[0] > a[0]
+	}
+	return a[0] == 0 && b[1] > a[1]
+}
+
+// compareVersions orders two semver-ish versions, ignoring range operators
+// and build metadata. A pre-release sorts before its release.
+func compareVersions(a, b string) int {
+	va, okA := parseSemver(a)
+	vb, okB := parseSemver(b)
+	if !okA || !okB {
+		return strings.Compare(a, b)
+	}
+	for i := 0; i < 3; i++ {
+		if va[i] != vb[i] {
+			if va[i] < vb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	preA, preB := prerelease(a), prerelease(b)
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	return strings.Compare(preA, preB)
+}
+
+var semverCore = regexp.MustCompile(`(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+func parseSemver(v string) ([3]int, bool) {
+	var out [3]int
+	m := semverCore.FindStringSubmatch(strings.TrimLeft(v, "^~<>=v "))
+	if m == nil {
+		return out, false
+	}
+	for i := 0; i < 3; i++ {
+		if m[i+1] != "" {
+			out[i], _ = strconv.Atoi(m[i+1])
+		}
+	}
+	return out, true
+}
+
+func prerelease(v string) string {
+	v = strings.SplitN(v, "+", 2)[0]
+	if i := strings.Index(v, "-"); i >= 0 {
+		return v[i+1:]
+	}
+	return ""
+}
@@ -4,20 +4,39 @@ package service
 
 import (
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 // FileChange represents a single file modified in a PR
 type FileChange struct {
 	Path     string 
+	OldPath  string // Path before a rename, the same as Path otherwise
 	Language string 
 	Content  string 
 	IsSafe   bool  
+	Hunks    []Hunk
+}
+
+// Hunk is the new-side line range covered by one "@@" block of a file diff.
+// GitHub only accepts inline review comments on lines inside these ranges.
+type Hunk struct {
+	NewStart   int
+	NewLines   int
+	AddedLines []int
+	AddedText  []string // Text of each added line, same order as AddedLines
 }
 
 const MaxFileSize = 20000
 
-type DiffParser struct{}
+type DiffParser struct {
+	// Skipped lists the generated files the last Parse left out
+	Skipped []SkippedFile
+
+	generatedGlobs []string
+	attributes     []GitAttribute
+	fetch          FileFetcher
+}
 
 func NewDiffParser() *DiffParser {
 	return &DiffParser{}
@@ -25,7 +44,20 @@ func NewDiffParser() *DiffParser {
 
 // Parse splits a raw diff string into a list of FileChange objects
 func (p *DiffParser) Parse(rawDiff string) []FileChange {
+	return p.parse(rawDiff, true)
+}
+
+// ParseAll is Parse without the junk filter, for scanners that must also see
+// lockfiles and .env files that are never sent to the AI
+func (p *DiffParser) ParseAll(rawDiff string) []FileChange {
+	return p.parse(rawDiff, false)
+}
+
+func (p *DiffParser) parse(rawDiff string, filter bool) []FileChange {
 	var files []FileChange
+	if filter {
+		p.Skipped = nil
+	}
 	
 	rawFiles := strings.Split(rawDiff, "diff --git ")
 
@@ -40,7 +72,7 @@ func (p *DiffParser) Parse(rawDiff string) []FileChange {
 		}
 
 		//  Filter Junk
-		if isIgnoredFile(path) {
+		if filter && isIgnoredFile(path) {
 			continue
 		}
 
@@ -49,6 +81,13 @@ func (p *DiffParser) Parse(rawDiff string) []FileChange {
 
 		//  HANDLE LARGE FILES
 		content := "diff --git " + rawFile
+		if filter {
+			if reason := p.generatedReason(path, content); reason != "" {
+				p.Skipped = append(p.Skipped, SkippedFile{Path: path, Reason: reason})
+				continue
+			}
+		}
+		hunks := parseHunks(content)
 		if len(content) > MaxFileSize {
 		
 			content = content[:MaxFileSize] + "\n... [TRUNCATED DUE TO SIZE] ..."
@@ -57,14 +96,109 @@ func (p *DiffParser) Parse(rawDiff string) []FileChange {
 
 		files = append(files, FileChange{
 			Path:     path,
+			OldPath:  extractOldPath(rawFile, path),
 			Language: lang,
 			Content:  content,
 			IsSafe:   true,
+			Hunks:    hunks,
 		})
 	}
 
 	return files
 }
+// HasLine reports whether a new-side line number is visible in the diff
+func (f FileChange) HasLine(line int) bool {
+	for _, h := range f.Hunks {
+		if line >= h.NewStart && line < h.NewStart+h.NewLines {
+			return true
+		}
+	}
+	return false
+}
+
+// HunkContains reports whether new-side lines start..end all sit inside one
+// hunk. GitHub rejects multi-line comments and suggestions that span hunks.
+func (f FileChange) HunkContains(start, end int) bool {
+	if start <= 0 || end < start {
+		return false
+	}
+	for _, h := range f.Hunks {
+		if start >= h.NewStart && end < h.NewStart+h.NewLines {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdded reports whether a new-side line number was added by this change
+func (f FileChange) IsAdded(line int) bool {
+	for _, h := range f.Hunks {
+		for _, l := range h.AddedLines {
+			if l == line {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseHunks walks the "@@ -a,b +c,d @@" headers of a file diff and records
+// which new-side lines each hunk covers and which of them were added.
+func parseHunks(content string) []Hunk {
+	var hunks []Hunk
+	var current *Hunk
+	newLine := 0
+
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			start, count, ok := parseHunkHeader(line)
+			if !ok {
+				current = nil
+				continue
+			}
+			hunks = append(hunks, Hunk{NewStart: start, NewLines: count})
+			current = &hunks[len(hunks)-1]
+			newLine = start
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "+"):
+			current.AddedLines = append(current.AddedLines, newLine)
+			current.AddedText = append(current.AddedText, line[1:])
+			newLine++
+		case strings.HasPrefix(line, " "):
+			newLine++
+		}
+	}
+	return hunks
+}
+
+// parseHunkHeader extracts the "+c,d" part of a hunk header
+func parseHunkHeader(header string) (int, int, bool) {
+	for _, field := range strings.Fields(header) {
+		if !strings.HasPrefix(field, "+") {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(field, "+"), ",", 2)
+		start, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, 0, false
+		}
+		count := 1
+		if len(parts) == 2 {
+			if count, err = strconv.Atoi(parts[1]); err != nil {
+				return 0, 0, false
+			}
+		}
+		return start, count, true
+	}
+	return 0, 0, false
+}
+
 // extractFilePath finds "a/backend/main.go b/backend/main.go" and returns "backend/main.go"
 func extractFilePath(rawChunk string) string {
 	lines := strings.Split(rawChunk, "\n")
@@ -79,6 +213,15 @@ func extractFilePath(rawChunk string) string {
 	return ""
 }
 
+// extractOldPath returns the "a/" side of the header, or path when there is none
+func extractOldPath(rawChunk, path string) string {
+	line, _, _ := strings.Cut(rawChunk, "\n")
+	if parts := strings.Fields(line); len(parts) >= 2 {
+		return strings.TrimPrefix(parts[0], "a/")
+	}
+	return path
+}
+
 // detectLanguage guesses language based on extension
 func detectLanguage(path string) string {
 	ext := strings.ToLower(filepath.Ext(path))
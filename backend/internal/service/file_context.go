@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxContextSize is the total budget for read-only file context in one prompt
+	MaxContextSize = 60000
+	// maxWholeFileSize is the largest file we include in full; bigger files
+	// only contribute the regions around their hunks
+	maxWholeFileSize = 12000
+	// contextWindow is how many lines around each hunk we keep for big files
+	contextWindow = 40
+)
+
+// FileFetcher returns the head version of a changed file
+type FileFetcher func(path string) (string, error)
+
+// BuildFileContext collects the head version of every changed file, whole
+// when it is small and as windows around the hunks otherwise, until budget
+// is spent. The model only sees three lines of context per hunk in the
+// diff, which is not enough to know what is declared higher up in a file.
+// It also returns the paths that made it in whole.
+func BuildFileContext(files []FileChange, fetch FileFetcher, budget int) (string, map[string]bool) {
+	var sb strings.Builder
+	whole := make(map[string]bool)
+
+	for _, f := range files {
+		remaining := budget - sb.Len()
+		if remaining <= 0 {
+			break
+		}
+
+		content, err := fetch(f.Path)
+		if err != nil {
+			// Deleted or binary files have nothing useful at head
+			continue
+		}
+
+		section := fileContextSection(f, content)
+		if section == "" {
+			continue
+		}
+		if len(section) > remaining {
+			continue
+		}
+		sb.WriteString(section)
+		if len(content) <= maxWholeFileSize {
+			whole[f.Path] = true
+		}
+	}
+
+	return sb.String(), whole
+}
+
+// fileContextSection renders either the whole file or its relevant regions
+// with line numbers, so findings can refer to real lines.
+func fileContextSection(f FileChange, content string) string {
+	lines := strings.Split(content, "\n")
+
+	if len(content) <= maxWholeFileSize {
+		return fmt.Sprintf("### FILE: %s (full file at head, read-only)\n%s\n", f.Path, numberLines(lines, 1, len(lines)))
+	}
+
+	var sb strings.Builder
+	for _, r := range hunkWindows(f.Hunks, len(lines)) {
+		sb.WriteString(numberLines(lines, r[0], r[1]))
+		sb.WriteString("...\n")
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("### FILE: %s (excerpts at head, read-only)\n%s\n", f.Path, sb.String())
+}
+
+// hunkWindows returns merged [start, end] line ranges around each hunk
+func hunkWindows(hunks []Hunk, total int) [][2]int {
+	var windows [][2]int
+	for _, h := range hunks {
+		start := h.NewStart - contextWindow
+		if start < 1 {
+			start = 1
+		}
+		end := h.NewStart + h.NewLines + contextWindow
+		if end > total {
+			end = total
+		}
+		if n := len(windows); n > 0 && start <= windows[n-1][1]+1 {
+			if end > windows[n-1][1] {
+				windows[n-1][1] = end
+			}
+			continue
+		}
+		windows = append(windows, [2]int{start, end})
+	}
+	return windows
+}
+
+// numberLines prints lines start..end (1-based, inclusive) prefixed with their number
+func numberLines(lines []string, start, end int) string {
+	var sb strings.Builder
+	for i := start; i <= end && i <= len(lines); i++ {
+		fmt.Fprintf(&sb, "%5d | %s\n", i, lines[i-1])
+	}
+	return sb.String()
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// generatedHeader is the convention from https://go.dev/s/generatedcode,
+// which protoc, mockgen, stringer and most JS/TS generators also follow.
+// "@generated" is the marker used by Meta's tooling.
+var generatedHeader = regexp.MustCompile(`^\s*(?://|#|/\*|\*|--)\s*(?:Code generated .*DO NOT EDIT\.?|@generated\b)`)
+
+// maxHeaderLines is how far into a file the generated header is looked for
+const maxHeaderLines = 30
+
+// SkippedFile is a changed file left out of the review
+type SkippedFile struct {
+	Path   string
+	Reason string
+}
+
+// GitAttribute is one "pattern attr..." line of .gitattributes that sets or
+// unsets linguist-generated
+type GitAttribute struct {
+	Pattern   string
+	Generated bool
+}
+
+// ParseGitAttributes keeps the linguist-generated entries of a .gitattributes file
+func ParseGitAttributes(content string) []GitAttribute {
+	var attrs []GitAttribute
+	for _, line := range splitLines(content) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			switch attr {
+			case "linguist-generated", "linguist-generated=true":
+				attrs = append(attrs, GitAttribute{Pattern: fields[0], Generated: true})
+			case "-linguist-generated", "linguist-generated=false", "!linguist-generated":
+				attrs = append(attrs, GitAttribute{Pattern: fields[0], Generated: false})
+			}
+		}
+	}
+	return attrs
+}
+
+// WithGenerated makes Parse skip generated files, detected by the configured
+// globs, by linguist-generated in .gitattributes, or by a generated-code
+// header. fetch is optional; it lets the header be found in modified files
+// whose hunks don't show the top of the file.
+func (p *DiffParser) WithGenerated(globs string, gitattributes string, fetch FileFetcher) *DiffParser {
+	p.generatedGlobs = splitLines(globs)
+	p.attributes = ParseGitAttributes(gitattributes)
+	p.fetch = fetch
+	return p
+}
+
+// StripSkipped removes the files skipped by the last Parse from a raw diff
+func (p *DiffParser) StripSkipped(rawDiff string) string {
+	if len(p.Skipped) == 0 {
+		return rawDiff
+	}
+	skipped := make(map[string]bool)
+	for _, s := range p.Skipped {
+		skipped[s.Path] = true
+	}
+	return filterDiff(rawDiff, func(path string) bool { return !skipped[path] })
+}
+
+// generatedReason returns why a file counts as generated, or ""
+func (p *DiffParser) generatedReason(path, content string) string {
+	for _, pattern := range p.generatedGlobs {
+		if MatchGlob(pattern, path) {
+			return fmt.Sprintf("matches `%s`", pattern)
+		}
+	}
+
+	// As in git, the last matching line wins
+	for i := len(p.attributes) - 1; i >= 0; i-- {
+		if MatchGlob(p.attributes[i].Pattern, path) {
+			if p.attributes[i].Generated {
+				return "marked `linguist-generated` in .gitattributes"
+			}
+			return ""
+		}
+	}
+
+	if hasGeneratedHeader(diffHeadLines(content)) {
+		return "has a `Code generated ... DO NOT EDIT` header"
+	}
+	if p.fetch != nil && !strings.Contains(content, "\n@@ -0,0 ") {
+		if src, err := p.fetch(path); err == nil && hasGeneratedHeader(strings.SplitN(src, "\n", maxHeaderLines+1)) {
+			return "has a `Code generated ... DO NOT EDIT` header"
+		}
+	}
+	return ""
+}
+
+// diffHeadLines returns the new-side text of the first lines of the file, if
+// the diff shows them
+func diffHeadLines(content string) []string {
+	var lines []string
+	inHead := false
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			start, _, ok := parseHunkHeader(line)
+			inHead = ok && start <= 1
+			continue
+		}
+		if !inHead || len(lines) >= maxHeaderLines {
+			continue
+		}
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, " ") {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+func hasGeneratedHeader(lines []string) bool {
+	for i, line := range lines {
+		if i >= maxHeaderLines {
+			break
+		}
+		if generatedHeader.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateGlobs checks newline-separated path globs from a setting
+func ValidateGlobs(patterns string) error {
+	for _, pattern := range splitLines(patterns) {
+		if _, err := globRegexp(pattern); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
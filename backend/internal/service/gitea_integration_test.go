@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGiteaIntegration runs the review calls against a real Gitea, such as
+// the one of the compose "gitea" profile:
+//
+//	docker compose --profile gitea up -d gitea
+//	docker exec -u git code_review_gitea gitea admin user create --admin \
+//		--username review --password review-pass --email review@example.com
+//	docker exec -u git code_review_gitea gitea admin user generate-access-token \
+//		--username review --scopes all --raw
+//	GITEA_URL=http://localhost:3001 GITEA_TOKEN=<token> go test ./internal/service -run Gitea
+func TestGiteaIntegration(t *testing.T) {
+	baseURL, token := os.Getenv("GITEA_URL"), os.Getenv("GITEA_TOKEN")
+	if baseURL == "" || token == "" {
+		t.Skip("GITEA_URL and GITEA_TOKEN not set, skipping Gitea integration test")
+	}
+	ctx := context.Background()
+	s := NewGiteaService(baseURL)
+	s.Token = token
+
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := s.do(ctx, http.MethodGet, "/user", nil, &user); err != nil {
+		t.Fatal(err)
+	}
+	owner, name := user.Login, fmt.Sprintf("review-it-%d", time.Now().UnixNano())
+	if err := s.do(ctx, http.MethodPost, "/user/repos", map[string]interface{}{"name": name, "auto_init": true, "default_branch": "main"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := s.do(ctx, http.MethodDelete, repoPath(owner, name), nil, nil); err != nil {
+			t.Logf("failed to delete %s/%s: %v", owner, name, err)
+		}
+	})
+
+	source := "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"
+	var file struct {
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+	err := s.do(ctx, http.MethodPost, repoPath(owner, name)+"/contents/cmd/main.go", map[string]string{
+		"content":    base64.StdEncoding.EncodeToString([]byte(source)),
+		"message":    "Add main",
+		"branch":     "main",
+		"new_branch": "feature",
+	}, &file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pr struct {
+		Number int `json:"number"`
+	}
+	err = s.do(ctx, http.MethodPost, repoPath(owner, name)+"/pulls", map[string]string{"head": "feature", "base": "main", "title": "Add main"}, &pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := s.GetPullRequestDiff(ctx, owner, name, pr.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "+++ b/cmd/main.go") {
+		t.Errorf("diff = %q", diff)
+	}
+
+	content, err := s.GetFileContent(ctx, owner, name, "cmd/main.go", file.Commit.SHA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != source {
+		t.Errorf("content = %q", content)
+	}
+
+	if commented, err := s.HasBotCommented(ctx, owner, name, pr.Number); err != nil || commented {
+		t.Fatalf("HasBotCommented before the review = %v, %v", commented, err)
+	}
+	if err := s.PostComment(ctx, owner, name, pr.Number, "## 🤖 AI Code Review\n\n"+BotCommentMarker); err != nil {
+		t.Fatal(err)
+	}
+	if commented, err := s.HasBotCommented(ctx, owner, name, pr.Number); err != nil || !commented {
+		t.Errorf("HasBotCommented after the review = %v, %v", commented, err)
+	}
+
+	posted, err := s.PostInlineComments(ctx, owner, name, pr.Number, file.Commit.SHA, "Findings", []InlineComment{
+		{Path: "cmd/main.go", Line: 4, Body: "Use fmt"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 1 || posted[0].Path != "cmd/main.go" || posted[0].ID == 0 {
+		t.Errorf("posted = %+v", posted)
+	}
+
+	if err := s.SetCommitStatus(ctx, owner, name, file.Commit.SHA, "success", "ai-code-review/test", "ok"); err != nil {
+		t.Error(err)
+	}
+}
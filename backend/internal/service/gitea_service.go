@@ -0,0 +1,214 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// GiteaService talks to the API (v1) of a Gitea or Forgejo instance
+type GiteaService struct {
+	BaseURL    string // e.g. https://git.example.com
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewGiteaService connects to the instance at baseURL with GITEA_TOKEN
+func NewGiteaService(baseURL string) *GiteaService {
+	return &GiteaService{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Token:      os.Getenv("GITEA_TOKEN"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+var _ SCMProvider = (*GiteaService)(nil)
+
+// do sends a request and decodes the JSON answer into out, when given
+func (s *GiteaService) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.BaseURL+"/api/v1"+path, reader)
+	if err != nil {
+		return err
+	}
+	if s.Token != "" {
+		req.Header.Set("Authorization", "token "+s.Token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("gitea %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func repoPath(owner, repo string) string {
+	return "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(repo)
+}
+
+type giteaComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+func (s *GiteaService) GetPullRequestDiff(ctx context.Context, owner, repo string, number int) (string, error) {
+	var raw []byte
+	if err := s.do(ctx, http.MethodGet, fmt.Sprintf("%s/pulls/%d.diff", repoPath(owner, repo), number), nil, &raw); err != nil {
+		return "", fmt.Errorf("failed to fetch PR diff: %w", err)
+	}
+	return string(raw), nil
+}
+
+// GetFileContent returns the raw file at ref. Contents at a commit SHA are
+// cached like GitHub's.
+func (s *GiteaService) GetFileContent(ctx context.Context, owner, repo, path, ref string) (string, error) {
+	key := "gitea:" + s.BaseURL + "/" + owner + "/" + repo + "@" + ref + ":" + path
+	cacheable := isCommitSHA(ref)
+	if cacheable {
+		if content, ok := fileCache.get(key); ok {
+			return content, nil
+		}
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		segments = append(segments, url.PathEscape(segment))
+	}
+	var raw []byte
+	apiPath := fmt.Sprintf("%s/raw/%s?ref=%s", repoPath(owner, repo), strings.Join(segments, "/"), url.QueryEscape(ref))
+	if err := s.do(ctx, http.MethodGet, apiPath, nil, &raw); err != nil {
+		return "", fmt.Errorf("failed to get %s@%s: %w", path, ref, err)
+	}
+
+	if cacheable {
+		fileCache.put(key, string(raw))
+	}
+	return string(raw), nil
+}
+
+func (s *GiteaService) listComments(ctx context.Context, owner, repo string, number int) ([]giteaComment, error) {
+	var comments []giteaComment
+	if err := s.do(ctx, http.MethodGet, fmt.Sprintf("%s/issues/%d/comments", repoPath(owner, repo), number), nil, &comments); err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	return comments, nil
+}
+
+func (s *GiteaService) HasBotCommented(ctx context.Context, owner, repo string, number int) (bool, error) {
+	comments, err := s.listComments(ctx, owner, repo, number)
+	if err != nil {
+		return false, err
+	}
+	for _, comment := range comments {
+		if strings.Contains(comment.Body, "## 🤖 AI Code Review") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *GiteaService) PostComment(ctx context.Context, owner, repo string, number int, body string) error {
+	if err := s.do(ctx, http.MethodPost, fmt.Sprintf("%s/issues/%d/comments", repoPath(owner, repo), number), map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to post comment: %w", err)
+	}
+	return nil
+}
+
+// UpsertComment edits the PR comment containing marker, or creates it if there is none
+func (s *GiteaService) UpsertComment(ctx context.Context, owner, repo string, number int, marker, body string) error {
+	comments, err := s.listComments(ctx, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	for _, comment := range comments {
+		if strings.Contains(comment.Body, marker) {
+			path := fmt.Sprintf("%s/issues/comments/%d", repoPath(owner, repo), comment.ID)
+			if err := s.do(ctx, http.MethodPatch, path, map[string]string{"body": body}, nil); err != nil {
+				return fmt.Errorf("failed to edit comment: %w", err)
+			}
+			return nil
+		}
+	}
+	return s.PostComment(ctx, owner, repo, number, body)
+}
+
+// PostInlineComments posts the comments as one COMMENT review. Gitea
+// comments are single-line, so multi-line ones are pinned to their last line.
+func (s *GiteaService) PostInlineComments(ctx context.Context, owner, repo string, number int, commitSHA, body string, comments []InlineComment) ([]PostedComment, error) {
+	type reviewComment struct {
+		Path        string `json:"path"`
+		Body        string `json:"body"`
+		NewPosition int    `json:"new_position"`
+	}
+	request := struct {
+		Body     string          `json:"body"`
+		CommitID string          `json:"commit_id,omitempty"`
+		Event    string          `json:"event"`
+		Comments []reviewComment `json:"comments"`
+	}{Body: body, CommitID: commitSHA, Event: "COMMENT"}
+	for _, c := range comments {
+		request.Comments = append(request.Comments, reviewComment{Path: c.Path, Body: c.Body, NewPosition: c.Line})
+	}
+
+	var review struct {
+		ID int64 `json:"id"`
+	}
+	reviews := fmt.Sprintf("%s/pulls/%d/reviews", repoPath(owner, repo), number)
+	if err := s.do(ctx, http.MethodPost, reviews, request, &review); err != nil {
+		return nil, fmt.Errorf("failed to create review: %w", err)
+	}
+
+	var created []struct {
+		ID       int64  `json:"id"`
+		Path     string `json:"path"`
+		Position int    `json:"position"`
+	}
+	if err := s.do(ctx, http.MethodGet, fmt.Sprintf("%s/%d/comments", reviews, review.ID), nil, &created); err != nil {
+		return nil, fmt.Errorf("failed to list review comments: %w", err)
+	}
+	posted := make([]PostedComment, 0, len(created))
+	for _, c := range created {
+		posted = append(posted, PostedComment{ID: c.ID, Path: c.Path, Line: c.Position})
+	}
+	return posted, nil
+}
+
+// SetCommitStatus takes the same states as GitHub
+func (s *GiteaService) SetCommitStatus(ctx context.Context, owner, repo, sha, state, statusContext, description string) error {
+	request := map[string]string{"state": state, "context": statusContext, "description": description}
+	if err := s.do(ctx, http.MethodPost, fmt.Sprintf("%s/statuses/%s", repoPath(owner, repo), sha), request, nil); err != nil {
+		return fmt.Errorf("failed to set commit status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newFakeGitea serves the Gitea API endpoints the review uses for octo/hello
+// and records the reviews it was sent
+func newFakeGitea(t *testing.T) (*GiteaService, *[]map[string]interface{}) {
+	t.Helper()
+	var reviews []map[string]interface{}
+
+	mux := http.NewServeMux()
+	authorized := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "token secret" {
+				http.Error(w, `{"message":"token is required"}`, http.StatusUnauthorized)
+				return
+			}
+			h(w, r)
+		}
+	}
+	mux.HandleFunc("/api/v1/repos/octo/hello/pulls/3.diff", authorized(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("diff --git a/main.go b/main.go\n"))
+	}))
+	mux.HandleFunc("/api/v1/repos/octo/hello/raw/", authorized(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.EscapedPath() + "@" + r.URL.Query().Get("ref")))
+	}))
+	mux.HandleFunc("/api/v1/repos/octo/hello/issues/3/comments", authorized(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":1,"body":"LGTM"},{"id":2,"body":"## 🤖 AI Code Review\n\nNo issues"}]`))
+	}))
+	mux.HandleFunc("/api/v1/repos/octo/hello/issues/4/comments", authorized(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":1,"body":"LGTM"}]`))
+	}))
+	mux.HandleFunc("/api/v1/repos/octo/hello/pulls/3/reviews", authorized(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		var review map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&review)
+		reviews = append(reviews, review)
+		w.Write([]byte(`{"id":9}`))
+	}))
+	mux.HandleFunc("/api/v1/repos/octo/hello/pulls/3/reviews/9/comments", authorized(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":101,"path":"main.go","position":12},{"id":102,"path":"util.go","position":4}]`))
+	}))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	s := NewGiteaService(server.URL + "/")
+	s.Token = "secret"
+	return s, &reviews
+}
+
+func TestGiteaGetPullRequestDiff(t *testing.T) {
+	s, _ := newFakeGitea(t)
+	diff, err := s.GetPullRequestDiff(context.Background(), "octo", "hello", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != "diff --git a/main.go b/main.go\n" {
+		t.Errorf("diff = %q", diff)
+	}
+
+	s.Token = "wrong"
+	if _, err := s.GetPullRequestDiff(context.Background(), "octo", "hello", 3); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("error with a bad token = %v, want the 401", err)
+	}
+}
+
+func TestGiteaGetFileContent(t *testing.T) {
+	s, _ := newFakeGitea(t)
+	content, err := s.GetFileContent(context.Background(), "octo", "hello", "docs/read me.md", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "/api/v1/repos/octo/hello/raw/docs/read%20me.md@main" {
+		t.Errorf("content = %q", content)
+	}
+}
+
+func TestGiteaHasBotCommented(t *testing.T) {
+	s, _ := newFakeGitea(t)
+	for number, want := range map[int]bool{3: true, 4: false} {
+		got, err := s.HasBotCommented(context.Background(), "octo", "hello", number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("HasBotCommented(#%d) = %v, want %v", number, got, want)
+		}
+	}
+}
+
+func TestGiteaPostInlineComments(t *testing.T) {
+	s, reviews := newFakeGitea(t)
+	posted, err := s.PostInlineComments(context.Background(), "octo", "hello", 3, "abc123", "Findings", []InlineComment{
+		{Path: "main.go", Line: 12, Body: "nil check"},
+		{Path: "util.go", Line: 4, StartLine: 2, Body: "simplify"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*reviews) != 1 {
+		t.Fatalf("%d reviews created, want 1", len(*reviews))
+	}
+	review := (*reviews)[0]
+	if review["event"] != "COMMENT" || review["commit_id"] != "abc123" || review["body"] != "Findings" {
+		t.Errorf("review = %v", review)
+	}
+	comments, _ := review["comments"].([]interface{})
+	if len(comments) != 2 {
+		t.Fatalf("review comments = %v", review["comments"])
+	}
+	second := comments[1].(map[string]interface{})
+	if second["path"] != "util.go" || second["new_position"] != float64(4) {
+		t.Errorf("multi-line comment sent as %v, want it on its last line", second)
+	}
+
+	want := []PostedComment{{ID: 101, Path: "main.go", Line: 12}, {ID: 102, Path: "util.go", Line: 4}}
+	if len(posted) != len(want) || posted[0] != want[0] || posted[1] != want[1] {
+		t.Errorf("posted = %+v, want %+v", posted, want)
+	}
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/go-github/v50/github"
+	"golang.org/x/oauth2"
+)
+
+// tokenRefreshMargin renews installation tokens (valid for an hour) before
+// a request could be made with an expired one
+const tokenRefreshMargin = 5 * time.Minute
+
+// GitHubApp authenticates as a GitHub App: app JWTs for the /app endpoints,
+// installation tokens for everything done on a repository
+type GitHubApp struct {
+	AppID   int64
+	BaseURL *url.URL // API root, github.com when nil (GITHUB_API_URL for the default app)
+
+	key *rsa.PrivateKey
+
+	mu            sync.Mutex
+	tokens        map[int64]*github.InstallationToken
+	refreshing    map[int64]chan struct{} // Closed once the running exchange is done
+	installations map[string]int64        // "owner/repo" -> installation ID
+}
+
+var (
+	defaultApp     *GitHubApp
+	defaultAppErr  error
+	defaultAppOnce sync.Once
+)
+
+// NewGitHubApp takes the app ID and its PEM private key
+func NewGitHubApp(appID int64, privateKeyPEM []byte) (*GitHubApp, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(privateKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub App private key: %w", err)
+	}
+	return &GitHubApp{
+		AppID:         appID,
+		key:           key,
+		tokens:        make(map[int64]*github.InstallationToken),
+		refreshing:    make(map[int64]chan struct{}),
+		installations: make(map[string]int64),
+	}, nil
+}
+
+// DefaultGitHubApp is the app configured by GITHUB_APP_ID and
+// GITHUB_APP_PRIVATE_KEY (or GITHUB_APP_PRIVATE_KEY_PATH). It is nil when
+// the service runs with a personal access token instead.
+func DefaultGitHubApp() (*GitHubApp, error) {
+	defaultAppOnce.Do(func() {
+		idValue := os.Getenv("GITHUB_APP_ID")
+		if idValue == "" {
+			return
+		}
+		appID, err := strconv.ParseInt(idValue, 10, 64)
+		if err != nil {
+			defaultAppErr = fmt.Errorf("invalid GITHUB_APP_ID: %w", err)
+			return
+		}
+
+		key := []byte(os.Getenv("GITHUB_APP_PRIVATE_KEY"))
+		if path := os.Getenv("GITHUB_APP_PRIVATE_KEY_PATH"); len(key) == 0 && path != "" {
+			if key, err = os.ReadFile(path); err != nil {
+				defaultAppErr = fmt.Errorf("failed to read GitHub App private key: %w", err)
+				return
+			}
+		}
+		if defaultApp, defaultAppErr = NewGitHubApp(appID, key); defaultAppErr != nil {
+			return
+		}
+
+		endpoints, err := DefaultGitHubEndpoints()
+		if err != nil {
+			defaultAppErr = err
+			return
+		}
+		if endpoints.Enterprise() {
+			defaultApp.BaseURL, _ = url.Parse(endpoints.APIURL)
+		}
+	})
+	return defaultApp, defaultAppErr
+}
+
+// JWT signs a token that authenticates as the app itself for 10 minutes
+func (a *GitHubApp) JWT() (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		// Backdated to allow for clock drift, as GitHub recommends
+		IssuedAt:  jwt.NewNumericDate(now.Add(-60 * time.Second)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+		Issuer:    strconv.FormatInt(a.AppID, 10),
+	})
+	return token.SignedString(a.key)
+}
+
+// appClient calls the API as the app
+func (a *GitHubApp) appClient(ctx context.Context) (*github.Client, error) {
+	signed, err := a.JWT()
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign app JWT: %w", err)
+	}
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: signed, TokenType: "Bearer"})
+	return a.withBaseURL(github.NewClient(oauth2.NewClient(ctx, ts))), nil
+}
+
+func (a *GitHubApp) withBaseURL(client *github.Client) *github.Client {
+	if a.BaseURL != nil {
+		base := *a.BaseURL
+		if !strings.HasSuffix(base.Path, "/") {
+			base.Path += "/"
+		}
+		client.BaseURL = &base
+	}
+	return client
+}
+
+// InstallationToken returns a cached token for the installation, exchanging
+// a new app JWT for one when it is missing or about to expire. Only one
+// exchange runs per installation; other callers wait for its result.
+func (a *GitHubApp) InstallationToken(ctx context.Context, installationID int64) (*github.InstallationToken, error) {
+	for {
+		a.mu.Lock()
+		if t, ok := a.tokens[installationID]; ok && time.Until(t.GetExpiresAt().Time) > tokenRefreshMargin {
+			a.mu.Unlock()
+			return t, nil
+		}
+		wait, busy := a.refreshing[installationID]
+		if !busy {
+			break
+		}
+		a.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// Still holding the lock from the loop
+	done := make(chan struct{})
+	a.refreshing[installationID] = done
+	a.mu.Unlock()
+
+	t, err := a.exchangeToken(ctx, installationID)
+
+	a.mu.Lock()
+	delete(a.refreshing, installationID)
+	if err == nil {
+		a.tokens[installationID] = t
+	}
+	a.mu.Unlock()
+	close(done)
+	return t, err
+}
+
+func (a *GitHubApp) exchangeToken(ctx context.Context, installationID int64) (*github.InstallationToken, error) {
+	client, err := a.appClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, _, err := client.Apps.CreateInstallationToken(ctx, installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create installation token: %w", err)
+	}
+	return t, nil
+}
+
+// ForgetInstallation drops what is cached for a removed installation
+func (a *GitHubApp) ForgetInstallation(installationID int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.tokens, installationID)
+	for repo, id := range a.installations {
+		if id == installationID {
+			delete(a.installations, repo)
+		}
+	}
+}
+
+// FindInstallation returns the installation ID covering owner/repo
+func (a *GitHubApp) FindInstallation(ctx context.Context, owner, repo string) (int64, error) {
+	key := owner + "/" + repo
+	a.mu.Lock()
+	id, ok := a.installations[key]
+	a.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	client, err := a.appClient(ctx)
+	if err != nil {
+		return 0, err
+	}
+	installation, _, err := client.Apps.FindRepositoryInstallation(ctx, owner, repo)
+	if err != nil {
+		return 0, fmt.Errorf("app is not installed on %s: %w", key, err)
+	}
+
+	a.mu.Lock()
+	a.installations[key] = installation.GetID()
+	a.mu.Unlock()
+	return installation.GetID(), nil
+}
+
+// Client returns an API client that authenticates as the installation and
+// refreshes its token as needed
+func (a *GitHubApp) Client(ctx context.Context, installationID int64) *github.Client {
+	ts := &installationTokenSource{ctx: ctx, app: a, installationID: installationID}
+	return a.withBaseURL(github.NewClient(&http.Client{Transport: &oauth2.Transport{Source: ts}}))
+}
+
+type installationTokenSource struct {
+	ctx            context.Context
+	app            *GitHubApp
+	installationID int64
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	t, err := s.app.InstallationToken(s.ctx, s.installationID)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: t.GetToken(), TokenType: "token", Expiry: t.GetExpiresAt().Time}, nil
+}
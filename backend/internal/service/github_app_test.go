@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// resetDefaults forgets the app and endpoints read from the environment, so
+// a test can configure its own
+func resetDefaults(t *testing.T) {
+	reset := func() {
+		defaultApp, defaultAppErr, defaultAppOnce = nil, nil, sync.Once{}
+		defaultEndpoints, defaultEndpointsErr, defaultEndpointsOnce = GitHubEndpoints{}, nil, sync.Once{}
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func testAppKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// fakeGitHub serves the app and OAuth endpoints of an Enterprise Server
+type fakeGitHub struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	exchanges atomic.Int32
+	lookups   atomic.Int32
+	expiresIn atomic.Int64 // Lifetime of the next installation token
+	slow      chan struct{} // When set, token exchanges wait for it to close
+}
+
+func newFakeGitHub(t *testing.T, key *rsa.PrivateKey) *fakeGitHub {
+	f := &fakeGitHub{key: key}
+	f.expiresIn.Store(int64(time.Hour))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		if !f.validJWT(r) {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		if f.slow != nil {
+			<-f.slow
+		}
+		n := f.exchanges.Add(1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":      fmt.Sprintf("ghs_%d", n),
+			"expires_at": time.Now().Add(time.Duration(f.expiresIn.Load())).UTC().Format(time.RFC3339),
+		})
+	})
+	mux.HandleFunc("/api/v3/repos/octo/hello/installation", func(w http.ResponseWriter, r *http.Request) {
+		if !f.validJWT(r) {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		f.lookups.Add(1)
+		w.Write([]byte(`{"id":42}`))
+	})
+	mux.HandleFunc("/api/v3/repos/octo/hello/pulls/7", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	})
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "code" {
+			http.Error(w, "bad code", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"gho_user","token_type":"bearer"}`))
+	})
+	mux.HandleFunc("/api/v3/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_user" {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"id":1,"login":"octocat"}`))
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeGitHub) validJWT(r *http.Request) bool {
+	signed, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	_, err := jwt.Parse(signed, func(*jwt.Token) (interface{}, error) { return &f.key.PublicKey, nil },
+		jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer("123"))
+	return err == nil
+}
+
+func newTestApp(t *testing.T, f *fakeGitHub, pemKey []byte) *GitHubApp {
+	t.Helper()
+	app, err := NewGitHubApp(123, pemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints, err := NewGitHubEndpoints(f.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.BaseURL, _ = parseBaseURL(endpoints.APIURL)
+	return app
+}
+
+func TestGitHubAppJWT(t *testing.T) {
+	key, pemKey := testAppKey(t)
+	app, err := NewGitHubApp(123, pemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := app.JWT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims jwt.RegisteredClaims
+	_, err = jwt.ParseWithClaims(signed, &claims, func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil },
+		jwt.WithValidMethods([]string{"RS256"}))
+	if err != nil {
+		t.Fatalf("JWT does not verify: %v", err)
+	}
+	if claims.Issuer != "123" {
+		t.Errorf("iss = %q, want 123", claims.Issuer)
+	}
+	if age := time.Since(claims.IssuedAt.Time); age < 59*time.Second || age > 62*time.Second {
+		t.Errorf("iat is %v in the past, want it backdated by a minute", age)
+	}
+	if life := claims.ExpiresAt.Sub(claims.IssuedAt.Time); life > 10*time.Minute {
+		t.Errorf("JWT lives %v, GitHub allows at most 10 minutes", life)
+	}
+
+	if _, err := NewGitHubApp(123, []byte("not a key")); err == nil {
+		t.Error("NewGitHubApp accepted an invalid key")
+	}
+}
+
+func TestInstallationTokenCaching(t *testing.T) {
+	key, pemKey := testAppKey(t)
+	f := newFakeGitHub(t, key)
+	app := newTestApp(t, f, pemKey)
+	ctx := context.Background()
+
+	first, err := app.InstallationToken(ctx, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := app.InstallationToken(ctx, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.exchanges.Load(); got != 1 {
+		t.Fatalf("%d token exchanges for two calls, want 1", got)
+	}
+	if first.GetToken() != second.GetToken() {
+		t.Errorf("cached token changed from %s to %s", first.GetToken(), second.GetToken())
+	}
+
+	// A token inside the refresh margin is renewed, every time it is asked for
+	app.ForgetInstallation(42)
+	f.expiresIn.Store(int64(tokenRefreshMargin / 2))
+	for i := 0; i < 2; i++ {
+		if _, err := app.InstallationToken(ctx, 42); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := f.exchanges.Load(); got != 3 {
+		t.Errorf("%d token exchanges, want 3: tokens about to expire must not be reused", got)
+	}
+
+	f.expiresIn.Store(int64(time.Hour))
+	renewed, err := app.InstallationToken(ctx, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := app.InstallationToken(ctx, 42); again.GetToken() != renewed.GetToken() || f.exchanges.Load() != 4 {
+		t.Errorf("renewed token was not cached (%d exchanges)", f.exchanges.Load())
+	}
+}
+
+func TestInstallationTokenSharedExchange(t *testing.T) {
+	key, pemKey := testAppKey(t)
+	f := newFakeGitHub(t, key)
+	f.slow = make(chan struct{})
+	app := newTestApp(t, f, pemKey)
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 5)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token, err := app.InstallationToken(context.Background(), 42)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			tokens[i] = token.GetToken()
+		}(i)
+	}
+
+	// The lock must not be held while the exchange is in flight
+	done := make(chan struct{})
+	go func() {
+		app.ForgetInstallation(7)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		close(f.slow)
+		t.Fatal("ForgetInstallation blocked behind a token exchange")
+	}
+
+	close(f.slow)
+	wg.Wait()
+	if got := f.exchanges.Load(); got != 1 {
+		t.Errorf("%d token exchanges for concurrent calls, want 1", got)
+	}
+	for _, token := range tokens {
+		if token != tokens[0] {
+			t.Errorf("callers got different tokens: %v", tokens)
+			break
+		}
+	}
+}
+
+func TestFindInstallation(t *testing.T) {
+	key, pemKey := testAppKey(t)
+	f := newFakeGitHub(t, key)
+	app := newTestApp(t, f, pemKey)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		id, err := app.FindInstallation(ctx, "octo", "hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != 42 {
+			t.Fatalf("installation = %d, want 42", id)
+		}
+	}
+	if got := f.lookups.Load(); got != 1 {
+		t.Errorf("%d lookups for two calls, want 1", got)
+	}
+
+	app.ForgetInstallation(42)
+	if _, err := app.FindInstallation(ctx, "octo", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.lookups.Load(); got != 2 {
+		t.Errorf("%d lookups after ForgetInstallation, want 2", got)
+	}
+
+	if _, err := app.FindInstallation(ctx, "octo", "missing"); err == nil {
+		t.Error("FindInstallation succeeded for a repository without the app")
+	}
+}
+
+func TestNewGitHubServiceFor(t *testing.T) {
+	key, pemKey := testAppKey(t)
+	f := newFakeGitHub(t, key)
+	ctx := context.Background()
+
+	t.Run("app", func(t *testing.T) {
+		resetDefaults(t)
+		t.Setenv("GITHUB_API_URL", f.URL)
+		t.Setenv("GITHUB_APP_ID", "123")
+		t.Setenv("GITHUB_APP_PRIVATE_KEY", string(pemKey))
+
+		gh, err := NewGitHubServiceFor(ctx, "octo", "hello", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth, err := gh.GetPullRequestDiff(ctx, "octo", "hello", 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(strings.ToLower(auth), "token ghs_") {
+			t.Errorf("request authenticated with %q, want the installation token", auth)
+		}
+		if f.lookups.Load() == 0 {
+			t.Error("installation 0 was not looked up")
+		}
+	})
+
+	t.Run("token", func(t *testing.T) {
+		resetDefaults(t)
+		t.Setenv("GITHUB_API_URL", f.URL)
+		t.Setenv("GITHUB_APP_ID", "")
+		t.Setenv("GITHUB_TOKEN", "pat")
+
+		gh, err := NewGitHubServiceFor(ctx, "octo", "hello", 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth, err := gh.GetPullRequestDiff(ctx, "octo", "hello", 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth != "Bearer pat" {
+			t.Errorf("request authenticated with %q, want GITHUB_TOKEN", auth)
+		}
+	})
+
+	t.Run("invalid app", func(t *testing.T) {
+		resetDefaults(t)
+		t.Setenv("GITHUB_APP_ID", "not a number")
+		if _, err := NewGitHubServiceFor(ctx, "octo", "hello", 42); err == nil {
+			t.Error("NewGitHubServiceFor ignored an invalid GITHUB_APP_ID")
+		}
+	})
+}
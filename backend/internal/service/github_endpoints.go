@@ -0,0 +1,153 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v50/github"
+	"golang.org/x/oauth2"
+	githuboauth "golang.org/x/oauth2/github"
+)
+
+// GitHubEndpoints locates the GitHub instance: github.com when empty, or a
+// GitHub Enterprise Server
+type GitHubEndpoints struct {
+	APIURL    string // e.g. https://ghe.example.com/api/v3/
+	UploadURL string // e.g. https://ghe.example.com/api/uploads/
+	OAuthURL  string // Web root serving /login/oauth/*, e.g. https://ghe.example.com
+}
+
+var (
+	defaultEndpoints     GitHubEndpoints
+	defaultEndpointsErr  error
+	defaultEndpointsOnce sync.Once
+)
+
+// DefaultGitHubEndpoints reads GITHUB_API_URL, GITHUB_UPLOAD_URL and
+// GITHUB_OAUTH_URL. The upload and OAuth URLs default to the ones of the
+// Enterprise Server the API URL belongs to.
+func DefaultGitHubEndpoints() (GitHubEndpoints, error) {
+	defaultEndpointsOnce.Do(func() {
+		defaultEndpoints, defaultEndpointsErr = NewGitHubEndpoints(
+			os.Getenv("GITHUB_API_URL"), os.Getenv("GITHUB_UPLOAD_URL"), os.Getenv("GITHUB_OAUTH_URL"))
+	})
+	return defaultEndpoints, defaultEndpointsErr
+}
+
+// NewGitHubEndpoints validates the URLs and fills in the ones left empty
+func NewGitHubEndpoints(apiURL, uploadURL, oauthURL string) (GitHubEndpoints, error) {
+	if apiURL == "" {
+		if uploadURL != "" || oauthURL != "" {
+			return GitHubEndpoints{}, fmt.Errorf("GITHUB_API_URL is required with GITHUB_UPLOAD_URL or GITHUB_OAUTH_URL")
+		}
+		return GitHubEndpoints{}, nil
+	}
+
+	api, err := parseBaseURL(apiURL)
+	if err != nil {
+		return GitHubEndpoints{}, fmt.Errorf("invalid GITHUB_API_URL: %w", err)
+	}
+	if api.Host == "api.github.com" {
+		if uploadURL != "" || oauthURL != "" {
+			return GitHubEndpoints{}, fmt.Errorf("GITHUB_UPLOAD_URL and GITHUB_OAUTH_URL only apply to GitHub Enterprise Server")
+		}
+		return GitHubEndpoints{}, nil
+	}
+	// Enterprise Server serves the API under /api/v3/, as go-github expects.
+	// Accept the server root, its /api/ path or the full API path.
+	switch {
+	case strings.HasSuffix(api.Path, "/api/v3/"):
+	case strings.HasSuffix(api.Path, "/api/"):
+		api.Path += "v3/"
+	default:
+		api.Path += "api/v3/"
+	}
+	root := *api
+	root.Path = strings.TrimSuffix(api.Path, "api/v3/")
+
+	e := GitHubEndpoints{APIURL: api.String()}
+	if uploadURL == "" {
+		upload := root
+		upload.Path += "api/uploads/"
+		e.UploadURL = upload.String()
+	} else {
+		upload, err := parseBaseURL(uploadURL)
+		if err != nil {
+			return GitHubEndpoints{}, fmt.Errorf("invalid GITHUB_UPLOAD_URL: %w", err)
+		}
+		e.UploadURL = upload.String()
+	}
+	if oauthURL == "" {
+		e.OAuthURL = strings.TrimSuffix(root.String(), "/")
+	} else {
+		oauth, err := parseBaseURL(oauthURL)
+		if err != nil {
+			return GitHubEndpoints{}, fmt.Errorf("invalid GITHUB_OAUTH_URL: %w", err)
+		}
+		e.OAuthURL = strings.TrimSuffix(oauth.String(), "/")
+	}
+	return e, nil
+}
+
+// parseBaseURL accepts absolute http(s) URLs and gives them a trailing slash
+func parseBaseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an http(s) URL", raw)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u, nil
+}
+
+// Enterprise reports whether the endpoints point at an Enterprise Server
+func (e GitHubEndpoints) Enterprise() bool {
+	return e.APIURL != ""
+}
+
+// NewClient creates an API client for the instance. httpClient carries the
+// authentication and may be nil.
+func (e GitHubEndpoints) NewClient(httpClient *http.Client) (*github.Client, error) {
+	if !e.Enterprise() {
+		return github.NewClient(httpClient), nil
+	}
+	return github.NewEnterpriseClient(e.APIURL, e.UploadURL, httpClient)
+}
+
+// OAuthEndpoint is where users authorize the OAuth app
+func (e GitHubEndpoints) OAuthEndpoint() oauth2.Endpoint {
+	if !e.Enterprise() {
+		return githuboauth.Endpoint
+	}
+	return oauth2.Endpoint{
+		AuthURL:  e.OAuthURL + "/login/oauth/authorize",
+		TokenURL: e.OAuthURL + "/login/oauth/access_token",
+	}
+}
+
+// NewGitHubClient creates an API client for the instance configured by
+// DefaultGitHubEndpoints, which main validates at startup
+func NewGitHubClient(httpClient *http.Client) *github.Client {
+	endpoints, _ := DefaultGitHubEndpoints()
+	client, err := endpoints.NewClient(httpClient)
+	if err != nil {
+		return github.NewClient(httpClient)
+	}
+	return client
+}
+
+// UserURL returns the authenticated user's profile
+func (e GitHubEndpoints) UserURL() string {
+	if !e.Enterprise() {
+		return "https://api.github.com/user"
+	}
+	return e.APIURL + "user"
+}
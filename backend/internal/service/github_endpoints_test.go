@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+	githuboauth "golang.org/x/oauth2/github"
+)
+
+func TestNewGitHubEndpoints(t *testing.T) {
+	tests := []struct {
+		name                    string
+		api, upload, oauth      string
+		wantAPI, wantUpload     string
+		wantOAuth               string
+		wantEnterprise, wantErr bool
+	}{
+		{name: "github.com"},
+		{name: "github.com API URL", api: "https://api.github.com"},
+		{name: "github.com API URL with slash", api: "https://api.github.com/"},
+		{name: "github.com with overrides", api: "https://api.github.com", oauth: "https://github.com", wantErr: true},
+		{
+			name: "server root", api: "https://ghe.example.com",
+			wantAPI: "https://ghe.example.com/api/v3/", wantUpload: "https://ghe.example.com/api/uploads/",
+			wantOAuth: "https://ghe.example.com", wantEnterprise: true,
+		},
+		{
+			name: "api path", api: "https://ghe.example.com/api",
+			wantAPI: "https://ghe.example.com/api/v3/", wantUpload: "https://ghe.example.com/api/uploads/",
+			wantOAuth: "https://ghe.example.com", wantEnterprise: true,
+		},
+		{
+			name: "full API path", api: "https://ghe.example.com/api/v3/",
+			wantAPI: "https://ghe.example.com/api/v3/", wantUpload: "https://ghe.example.com/api/uploads/",
+			wantOAuth: "https://ghe.example.com", wantEnterprise: true,
+		},
+		{
+			name: "under a prefix", api: "http://proxy.internal/github/api/v3",
+			wantAPI: "http://proxy.internal/github/api/v3/", wantUpload: "http://proxy.internal/github/api/uploads/",
+			wantOAuth: "http://proxy.internal/github", wantEnterprise: true,
+		},
+		{
+			name: "explicit upload and OAuth", api: "https://ghe.example.com/api/v3",
+			upload: "https://uploads.ghe.example.com", oauth: "https://login.ghe.example.com/",
+			wantAPI: "https://ghe.example.com/api/v3/", wantUpload: "https://uploads.ghe.example.com/",
+			wantOAuth: "https://login.ghe.example.com", wantEnterprise: true,
+		},
+		{name: "upload without API", upload: "https://uploads.ghe.example.com", wantErr: true},
+		{name: "not http", api: "ftp://ghe.example.com", wantErr: true},
+		{name: "relative", api: "ghe.example.com", wantErr: true},
+		{name: "invalid OAuth URL", api: "https://ghe.example.com", oauth: "login", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewGitHubEndpoints(tt.api, tt.upload, tt.oauth)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("NewGitHubEndpoints(%q, %q, %q) = %+v, want an error", tt.api, tt.upload, tt.oauth, e)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.APIURL != tt.wantAPI || e.UploadURL != tt.wantUpload || e.OAuthURL != tt.wantOAuth {
+				t.Errorf("endpoints = %+v, want API %q, upload %q, OAuth %q", e, tt.wantAPI, tt.wantUpload, tt.wantOAuth)
+			}
+			if e.Enterprise() != tt.wantEnterprise {
+				t.Errorf("Enterprise() = %v, want %v", e.Enterprise(), tt.wantEnterprise)
+			}
+		})
+	}
+}
+
+func TestGitHubEndpointsNewClient(t *testing.T) {
+	client, err := GitHubEndpoints{}.NewClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := client.BaseURL.String(); got != "https://api.github.com/" {
+		t.Errorf("github.com base URL = %q", got)
+	}
+
+	e, err := NewGitHubEndpoints("https://ghe.example.com/api", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err = e.NewClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := client.BaseURL.String(); got != "https://ghe.example.com/api/v3/" {
+		t.Errorf("Enterprise base URL = %q", got)
+	}
+	if got := client.UploadURL.String(); got != "https://ghe.example.com/api/uploads/" {
+		t.Errorf("Enterprise upload URL = %q", got)
+	}
+}
+
+func TestGitHubEndpointsOAuth(t *testing.T) {
+	if got := (GitHubEndpoints{}).OAuthEndpoint(); got != githuboauth.Endpoint {
+		t.Errorf("github.com OAuth endpoint = %+v", got)
+	}
+	if got := (GitHubEndpoints{}).UserURL(); got != "https://api.github.com/user" {
+		t.Errorf("github.com user URL = %q", got)
+	}
+
+	e, err := NewGitHubEndpoints("https://ghe.example.com/api/v3/", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := e.OAuthEndpoint()
+	if endpoint.AuthURL != "https://ghe.example.com/login/oauth/authorize" ||
+		endpoint.TokenURL != "https://ghe.example.com/login/oauth/access_token" {
+		t.Errorf("Enterprise OAuth endpoint = %+v", endpoint)
+	}
+	if got := e.UserURL(); got != "https://ghe.example.com/api/v3/user" {
+		t.Errorf("Enterprise user URL = %q", got)
+	}
+}
+
+// The default app talks to the Enterprise Server in GITHUB_API_URL, whatever
+// form the URL is given in
+func TestDefaultGitHubAppEnterprise(t *testing.T) {
+	key, pemKey := testAppKey(t)
+	f := newFakeGitHub(t, key)
+
+	for _, apiURL := range []string{f.URL, f.URL + "/api", f.URL + "/api/v3"} {
+		t.Run(apiURL, func(t *testing.T) {
+			resetDefaults(t)
+			t.Setenv("GITHUB_APP_ID", "123")
+			t.Setenv("GITHUB_APP_PRIVATE_KEY", string(pemKey))
+			t.Setenv("GITHUB_API_URL", apiURL)
+
+			app, err := DefaultGitHubApp()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if app.BaseURL.String() != f.URL+"/api/v3/" {
+				t.Errorf("BaseURL = %q", app.BaseURL)
+			}
+
+			// A base URL without its trailing slash still resolves under /api/v3/
+			app.BaseURL, _ = url.Parse(f.URL + "/api/v3")
+			installationID, err := app.FindInstallation(context.Background(), "octo", "hello")
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := app.Client(context.Background(), installationID)
+			req, err := client.NewRequest(http.MethodGet, "repos/octo/hello/pulls/7", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var auth bytes.Buffer
+			if _, err := client.Do(context.Background(), req, &auth); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(auth.String(), "token ghs_") {
+				t.Errorf("pull request fetched with %q, want the installation token", auth)
+			}
+		})
+	}
+}
+
+// The OAuth code exchange and profile lookup go to the Enterprise Server
+func TestGitHubEndpointsOAuthFlow(t *testing.T) {
+	f := newFakeGitHub(t, nil)
+	e, err := NewGitHubEndpoints(f.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: e.OAuthEndpoint()}
+	if !strings.HasPrefix(conf.AuthCodeURL("state"), f.URL+"/login/oauth/authorize?") {
+		t.Errorf("AuthCodeURL = %q", conf.AuthCodeURL("state"))
+	}
+	token, err := conf.Exchange(context.Background(), "code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := conf.Client(context.Background(), token).Get(e.UserURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"id":1,"login":"octocat"}` {
+		t.Errorf("user = %s", body)
+	}
+}
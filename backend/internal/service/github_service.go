@@ -2,9 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
-	"strings" 
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/v50/github"
 	"golang.org/x/oauth2"
@@ -14,10 +18,58 @@ type GitHubService struct {
 	Client *github.Client
 }
 
+// maxCachedFiles bounds the per-SHA file cache shared by all tasks
+const maxCachedFiles = 500
+
+var fileCache = &contentCache{entries: make(map[string]string)}
+
+// contentCache holds file contents keyed by "owner/repo@sha:path"
+type contentCache struct {
+	mu      sync.Mutex
+	entries map[string]string
+	order   []string
+}
+
+func (c *contentCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	content, ok := c.entries[key]
+	return content, ok
+}
+
+func (c *contentCache) put(key, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	// Evict the oldest entry first
+	if len(c.order) >= maxCachedFiles {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[key] = content
+	c.order = append(c.order, key)
+}
+
+func isCommitSHA(ref string) bool {
+	if len(ref) != 40 {
+		return false
+	}
+	for _, r := range ref {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewGitHubService authenticates with GITHUB_TOKEN against github.com or the
+// Enterprise Server set by GITHUB_API_URL
 func NewGitHubService() *GitHubService {
 	token := os.Getenv("GITHUB_TOKEN")
 	if token == "" {
-		return &GitHubService{Client: github.NewClient(nil)}
+		return &GitHubService{Client: NewGitHubClient(nil)}
 	}
 
 	ctx := context.Background()
@@ -26,7 +78,27 @@ func NewGitHubService() *GitHubService {
 	)
 	tc := oauth2.NewClient(ctx, ts)
 
-	return &GitHubService{Client: github.NewClient(tc)}
+	return &GitHubService{Client: NewGitHubClient(tc)}
+}
+
+// NewGitHubServiceFor acts on owner/repo as the GitHub App installation when
+// the service runs as an app, and with GITHUB_TOKEN otherwise. installationID
+// comes from the webhook; 0 looks it up.
+func NewGitHubServiceFor(ctx context.Context, owner, repo string, installationID int64) (*GitHubService, error) {
+	app, err := DefaultGitHubApp()
+	if err != nil {
+		return nil, err
+	}
+	if app == nil {
+		return NewGitHubService(), nil
+	}
+
+	if installationID == 0 {
+		if installationID, err = app.FindInstallation(ctx, owner, repo); err != nil {
+			return nil, err
+		}
+	}
+	return &GitHubService{Client: app.Client(ctx, installationID)}, nil
 }
 
 func (s *GitHubService) GetPullRequestDiff(ctx context.Context, owner, repo string, prNumber int) (string, error) {
@@ -38,6 +110,96 @@ func (s *GitHubService) GetPullRequestDiff(ctx context.Context, owner, repo stri
 	return diff, nil
 }
 
+// GetCompareDiff returns the diff of the commits pushed between base and head
+func (s *GitHubService) GetCompareDiff(ctx context.Context, owner, repo, base, head string) (string, error) {
+	diff, _, err := s.Client.Repositories.CompareCommitsRaw(ctx, owner, repo, base, head, github.RawOptions{Type: github.Diff})
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch compare diff: %w", err)
+	}
+	return diff, nil
+}
+
+// InMergedPullRequest reports whether a commit came in through a merged PR
+func (s *GitHubService) InMergedPullRequest(ctx context.Context, owner, repo, sha string) (bool, error) {
+	prs, _, err := s.Client.PullRequests.ListPullRequestsWithCommit(ctx, owner, repo, sha, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to list pull requests of %s: %w", sha, err)
+	}
+	for _, pr := range prs {
+		if !pr.GetMergedAt().IsZero() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PostCommitComment comments on a commit as a whole
+func (s *GitHubService) PostCommitComment(ctx context.Context, owner, repo, sha, body string) error {
+	if _, _, err := s.Client.Repositories.CreateComment(ctx, owner, repo, sha, &github.RepositoryComment{Body: &body}); err != nil {
+		return fmt.Errorf("failed to post commit comment: %w", err)
+	}
+	return nil
+}
+
+// maxAnnotationsPerRequest is GitHub's limit per check run create/update
+const maxAnnotationsPerRequest = 50
+
+// StartCheckRun marks the named check run on a commit in progress. A run
+// left by an earlier attempt is reused, so retries don't stack up checks.
+func (s *GitHubService) StartCheckRun(ctx context.Context, owner, repo, sha, name string) (int64, error) {
+	runs, _, err := s.Client.Checks.ListCheckRunsForRef(ctx, owner, repo, sha, &github.ListCheckRunsOptions{
+		CheckName: github.String(name),
+		Filter:    github.String("latest"),
+	})
+	if err == nil && len(runs.CheckRuns) > 0 {
+		id := runs.CheckRuns[0].GetID()
+		_, _, err := s.Client.Checks.UpdateCheckRun(ctx, owner, repo, id, github.UpdateCheckRunOptions{
+			Name:   name,
+			Status: github.String("in_progress"),
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to restart check run: %w", err)
+		}
+		return id, nil
+	}
+
+	run, _, err := s.Client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+		Name:    name,
+		HeadSHA: sha,
+		Status:  github.String("in_progress"),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create check run: %w", err)
+	}
+	return run.GetID(), nil
+}
+
+// CompleteCheckRun sets the conclusion and output of a check run. The
+// annotations are sent in batches, which GitHub appends to each other.
+func (s *GitHubService) CompleteCheckRun(ctx context.Context, owner, repo string, id int64, name, conclusion, title, summary string, annotations []*github.CheckRunAnnotation) error {
+	for first := true; first || len(annotations) > 0; first = false {
+		batch := annotations
+		if len(batch) > maxAnnotationsPerRequest {
+			batch = batch[:maxAnnotationsPerRequest]
+		}
+		annotations = annotations[len(batch):]
+
+		opts := github.UpdateCheckRunOptions{
+			Name:   name,
+			Output: &github.CheckRunOutput{Title: &title, Summary: &summary, Annotations: batch},
+		}
+		if first {
+			opts.Status = github.String("completed")
+			opts.Conclusion = &conclusion
+			opts.CompletedAt = &github.Timestamp{Time: time.Now()}
+		}
+		if _, _, err := s.Client.Checks.UpdateCheckRun(ctx, owner, repo, id, opts); err != nil {
+			return fmt.Errorf("failed to complete check run: %w", err)
+		}
+	}
+	return nil
+}
+
 func (s *GitHubService) PostComment(ctx context.Context, owner, repo string, prNumber int, commentBody string) error {
 	comment := &github.IssueComment{
 		Body: &commentBody,
@@ -63,4 +225,369 @@ func (s *GitHubService) HasBotCommented(ctx context.Context, owner, repo string,
 		}
 	}
 	return false, nil
-}
\ No newline at end of file
+}
+
+// PostReview submits a COMMENT review with inline comments pinned to commitSHA
+// and returns the inline comments GitHub created, in the order they were sent.
+func (s *GitHubService) PostReview(ctx context.Context, owner, repo string, prNumber int, commitSHA, body string, comments []*github.DraftReviewComment) ([]*github.PullRequestComment, error) {
+	review, _, err := s.Client.PullRequests.CreateReview(ctx, owner, repo, prNumber, &github.PullRequestReviewRequest{
+		CommitID: github.String(commitSHA),
+		Body:     github.String(body),
+		Event:    github.String("COMMENT"),
+		Comments: comments,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create review: %w", err)
+	}
+
+	var created []*github.PullRequestComment
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := s.Client.PullRequests.ListReviewComments(ctx, owner, repo, prNumber, review.GetID(), opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list review comments: %w", err)
+		}
+		created = append(created, page...)
+		if resp.NextPage == 0 {
+			return created, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// PostInlineComments posts the comments as one COMMENT review
+func (s *GitHubService) PostInlineComments(ctx context.Context, owner, repo string, prNumber int, commitSHA, body string, comments []InlineComment) ([]PostedComment, error) {
+	var drafts []*github.DraftReviewComment
+	for _, c := range comments {
+		draft := &github.DraftReviewComment{
+			Path: github.String(c.Path),
+			Line: github.Int(c.Line),
+			Side: github.String("RIGHT"),
+			Body: github.String(c.Body),
+		}
+		if c.StartLine > 0 && c.StartLine < c.Line {
+			draft.StartLine = github.Int(c.StartLine)
+			draft.StartSide = github.String("RIGHT")
+		}
+		drafts = append(drafts, draft)
+	}
+
+	created, err := s.PostReview(ctx, owner, repo, prNumber, commitSHA, body, drafts)
+	if err != nil {
+		return nil, err
+	}
+	posted := make([]PostedComment, 0, len(created))
+	for _, c := range created {
+		posted = append(posted, PostedComment{ID: c.GetID(), Path: c.GetPath(), Line: c.GetLine()})
+	}
+	return posted, nil
+}
+
+// GetReviewComment fetches a single inline comment, including its diff hunk
+func (s *GitHubService) GetReviewComment(ctx context.Context, owner, repo string, commentID int64) (*github.PullRequestComment, error) {
+	comment, _, err := s.Client.PullRequests.GetComment(ctx, owner, repo, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review comment: %w", err)
+	}
+	return comment, nil
+}
+
+// ReplyToReviewComment posts a reply in the thread started by commentID
+func (s *GitHubService) ReplyToReviewComment(ctx context.Context, owner, repo string, prNumber int, commentID int64, body string) (int64, error) {
+	reply, _, err := s.Client.PullRequests.CreateCommentInReplyTo(ctx, owner, repo, prNumber, body, commentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reply to comment: %w", err)
+	}
+	return reply.GetID(), nil
+}
+
+// UpsertComment edits the PR comment containing marker, or creates it if there is none
+func (s *GitHubService) UpsertComment(ctx context.Context, owner, repo string, prNumber int, marker, body string) error {
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := s.Client.Issues.ListComments(ctx, owner, repo, prNumber, opts)
+		if err != nil {
+			return fmt.Errorf("failed to list comments: %w", err)
+		}
+
+		for _, comment := range comments {
+			if strings.Contains(comment.GetBody(), marker) {
+				_, _, err := s.Client.Issues.EditComment(ctx, owner, repo, comment.GetID(), &github.IssueComment{Body: &body})
+				if err != nil {
+					return fmt.Errorf("failed to edit comment: %w", err)
+				}
+				return nil
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return s.PostComment(ctx, owner, repo, prNumber, body)
+}
+
+// UpdateDescriptionSection replaces the text between startMarker and endMarker
+// in the PR description, appending the section if the markers are missing.
+// Everything the author wrote outside the markers is kept as is.
+func (s *GitHubService) UpdateDescriptionSection(ctx context.Context, owner, repo string, prNumber int, startMarker, endMarker, section string) error {
+	pr, _, err := s.Client.PullRequests.Get(ctx, owner, repo, prNumber)
+	if err != nil {
+		return fmt.Errorf("failed to get PR: %w", err)
+	}
+
+	block := startMarker + "\n" + section + "\n" + endMarker
+	body := pr.GetBody()
+	start := strings.Index(body, startMarker)
+	end := strings.Index(body, endMarker)
+	if start >= 0 && end > start {
+		body = body[:start] + block + body[end+len(endMarker):]
+	} else if strings.TrimSpace(body) == "" {
+		body = block
+	} else {
+		body = strings.TrimRight(body, "\n") + "\n\n" + block
+	}
+
+	if _, _, err := s.Client.PullRequests.Edit(ctx, owner, repo, prNumber, &github.PullRequest{Body: &body}); err != nil {
+		return fmt.Errorf("failed to update PR description: %w", err)
+	}
+	return nil
+}
+
+// GetPullRequest fetches the PR metadata (head/base refs and SHAs)
+func (s *GitHubService) GetPullRequest(ctx context.Context, owner, repo string, prNumber int) (*github.PullRequest, error) {
+	pr, _, err := s.Client.PullRequests.Get(ctx, owner, repo, prNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PR: %w", err)
+	}
+	return pr, nil
+}
+
+// GetFileContent returns the decoded contents of path at ref. Contents at a
+// commit SHA never change, so those are cached across tasks.
+func (s *GitHubService) GetFileContent(ctx context.Context, owner, repo, path, ref string) (string, error) {
+	key := owner + "/" + repo + "@" + ref + ":" + path
+	cacheable := isCommitSHA(ref)
+	if cacheable {
+		if content, ok := fileCache.get(key); ok {
+			return content, nil
+		}
+	}
+
+	file, _, _, err := s.Client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s@%s: %w", path, ref, err)
+	}
+	if file == nil {
+		return "", fmt.Errorf("%s is not a file", path)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return "", err
+	}
+
+	if cacheable {
+		fileCache.put(key, content)
+	}
+	return content, nil
+}
+
+// CommitFiles points branch at one new commit on top of baseSHA that replaces
+// the given files, using the Git data API so nothing has to be cloned. An
+// existing branch is force-updated, so a retried or repeated run reuses it.
+func (s *GitHubService) CommitFiles(ctx context.Context, owner, repo, baseSHA, branch, message string, files map[string]string) (string, error) {
+	base, _, err := s.Client.Git.GetCommit(ctx, owner, repo, baseSHA)
+	if err != nil {
+		return "", fmt.Errorf("failed to get base commit: %w", err)
+	}
+
+	// Keep the existing file modes (e.g. executable scripts)
+	modes := make(map[string]string)
+	if tree, _, err := s.Client.Git.GetTree(ctx, owner, repo, base.GetTree().GetSHA(), true); err == nil {
+		for _, entry := range tree.Entries {
+			modes[entry.GetPath()] = entry.GetMode()
+		}
+	}
+
+	var entries []*github.TreeEntry
+	for path, content := range files {
+		mode := modes[path]
+		if mode == "" {
+			mode = "100644"
+		}
+		entries = append(entries, &github.TreeEntry{
+			Path:    github.String(path),
+			Mode:    github.String(mode),
+			Type:    github.String("blob"),
+			Content: github.String(content),
+		})
+	}
+
+	tree, _, err := s.Client.Git.CreateTree(ctx, owner, repo, base.GetTree().GetSHA(), entries)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tree: %w", err)
+	}
+
+	commit, _, err := s.Client.Git.CreateCommit(ctx, owner, repo, &github.Commit{
+		Message: github.String(message),
+		Tree:    &github.Tree{SHA: tree.SHA},
+		Parents: []*github.Commit{{SHA: github.String(baseSHA)}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create commit: %w", err)
+	}
+
+	ref := &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: commit.SHA},
+	}
+	_, _, err = s.Client.Git.GetRef(ctx, owner, repo, "refs/heads/"+branch)
+	switch {
+	case err == nil:
+		if _, _, err := s.Client.Git.UpdateRef(ctx, owner, repo, ref, true); err != nil {
+			return "", fmt.Errorf("failed to update branch %s: %w", branch, err)
+		}
+	case isNotFound(err):
+		if _, _, err := s.Client.Git.CreateRef(ctx, owner, repo, ref); err != nil {
+			return "", fmt.Errorf("failed to create branch %s: %w", branch, err)
+		}
+	default:
+		return "", fmt.Errorf("failed to look up branch %s: %w", branch, err)
+	}
+	return commit.GetSHA(), nil
+}
+
+// FindOpenPullRequest returns the open PR from head ("owner:branch") into
+// base, or nil if there is none
+func (s *GitHubService) FindOpenPullRequest(ctx context.Context, owner, repo, head, base string) (*github.PullRequest, error) {
+	prs, _, err := s.Client.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{State: "open", Head: head, Base: base})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PRs from %s: %w", head, err)
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	return prs[0], nil
+}
+
+// CanPush reports whether user has write or admin access to the repository
+func (s *GitHubService) CanPush(ctx context.Context, owner, repo, user string) (bool, error) {
+	level, _, err := s.Client.Repositories.GetPermissionLevel(ctx, owner, repo, user)
+	if err != nil {
+		return false, fmt.Errorf("failed to get permission of %s: %w", user, err)
+	}
+	switch level.GetPermission() {
+	case "admin", "write":
+		return true, nil
+	}
+	return false, nil
+}
+
+func isNotFound(err error) bool {
+	var errResp *github.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound
+}
+
+// OpenPullRequest opens a PR from head into base and returns it
+func (s *GitHubService) OpenPullRequest(ctx context.Context, owner, repo, title, head, base, body string) (*github.PullRequest, error) {
+	pr, _, err := s.Client.PullRequests.Create(ctx, owner, repo, &github.NewPullRequest{
+		Title: github.String(title),
+		Head:  github.String(head),
+		Base:  github.String(base),
+		Body:  github.String(body),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PR: %w", err)
+	}
+	return pr, nil
+}
+
+// ListDirectory returns the names of the files directly inside dir at ref
+func (s *GitHubService) ListDirectory(ctx context.Context, owner, repo, dir, ref string) ([]string, error) {
+	if dir == "." {
+		dir = ""
+	}
+	_, entries, _, err := s.Client.Repositories.GetContents(ctx, owner, repo, dir, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s@%s: %w", dir, ref, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.GetType() == "file" {
+			names = append(names, entry.GetName())
+		}
+	}
+	return names, nil
+}
+
+// SetCommitStatus reports a status check (state: success, failure, pending, error) on a commit
+func (s *GitHubService) SetCommitStatus(ctx context.Context, owner, repo, sha, state, statusContext, description string) error {
+	_, _, err := s.Client.Repositories.CreateStatus(ctx, owner, repo, sha, &github.RepoStatus{
+		State:       github.String(state),
+		Context:     github.String(statusContext),
+		Description: github.String(description),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set commit status: %w", err)
+	}
+	return nil
+}
+
+// SyncLabels makes the PR carry exactly the desired labels out of the managed
+// set. Labels outside the managed set are never touched.
+func (s *GitHubService) SyncLabels(ctx context.Context, owner, repo string, prNumber int, desired, managed []string) error {
+	current, _, err := s.Client.Issues.ListLabelsByIssue(ctx, owner, repo, prNumber, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return fmt.Errorf("failed to list labels: %w", err)
+	}
+
+	want := make(map[string]bool)
+	for _, name := range desired {
+		want[name] = true
+	}
+	isManaged := make(map[string]bool)
+	for _, name := range managed {
+		isManaged[name] = true
+	}
+
+	have := make(map[string]bool)
+	for _, label := range current {
+		name := label.GetName()
+		have[name] = true
+		if isManaged[name] && !want[name] {
+			if _, err := s.Client.Issues.RemoveLabelForIssue(ctx, owner, repo, prNumber, name); err != nil {
+				return fmt.Errorf("failed to remove label %q: %w", name, err)
+			}
+		}
+	}
+
+	var missing []string
+	for _, name := range desired {
+		if !have[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if _, _, err := s.Client.Issues.AddLabelsToIssue(ctx, owner, repo, prNumber, missing); err != nil {
+		return fmt.Errorf("failed to add labels: %w", err)
+	}
+	return nil
+}
+
+// RequestReviewers asks users and teams (by slug) to review a PR
+func (s *GitHubService) RequestReviewers(ctx context.Context, owner, repo string, prNumber int, users, teams []string) error {
+	if len(users) == 0 && len(teams) == 0 {
+		return nil
+	}
+	_, _, err := s.Client.PullRequests.RequestReviewers(ctx, owner, repo, prNumber, github.ReviewersRequest{
+		Reviewers:     users,
+		TeamReviewers: teams,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to request reviewers: %w", err)
+	}
+	return nil
+}
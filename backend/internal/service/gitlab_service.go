@@ -0,0 +1,270 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// GitLabService talks to the GitLab REST API (v4) of gitlab.com or a
+// self-managed instance
+type GitLabService struct {
+	BaseURL    string // e.g. https://gitlab.com
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewGitLabService connects to baseURL with GITLAB_TOKEN. Without a base URL
+// it uses GITLAB_URL, then https://gitlab.com.
+func NewGitLabService(baseURL string) *GitLabService {
+	if baseURL == "" {
+		baseURL = os.Getenv("GITLAB_URL")
+	}
+	if baseURL == "" {
+		baseURL = "https://gitlab.com"
+	}
+	return &GitLabService{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Token:      os.Getenv("GITLAB_TOKEN"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+var _ SCMProvider = (*GitLabService)(nil)
+
+// projectPath is the URL-encoded "namespace/project" the API takes as an ID
+func projectPath(owner, repo string) string {
+	return url.PathEscape(owner + "/" + repo)
+}
+
+// do sends a request and decodes the JSON answer into out, when given
+func (s *GitLabService) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.BaseURL+"/api/v4"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", s.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("gitlab %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type gitlabNote struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+type gitlabMergeRequest struct {
+	IID      int `json:"iid"`
+	DiffRefs struct {
+		BaseSHA  string `json:"base_sha"`
+		StartSHA string `json:"start_sha"`
+		HeadSHA  string `json:"head_sha"`
+	} `json:"diff_refs"`
+}
+
+// GetPullRequestDiff rebuilds a unified diff, in the format of GitHub's
+// .diff output, from the merge request's changes
+func (s *GitLabService) GetPullRequestDiff(ctx context.Context, owner, repo string, number int) (string, error) {
+	var mr struct {
+		Changes []struct {
+			OldPath     string `json:"old_path"`
+			NewPath     string `json:"new_path"`
+			Diff        string `json:"diff"`
+			NewFile     bool   `json:"new_file"`
+			DeletedFile bool   `json:"deleted_file"`
+		} `json:"changes"`
+	}
+	path := fmt.Sprintf("/projects/%s/merge_requests/%d/changes?access_raw_diffs=true", projectPath(owner, repo), number)
+	if err := s.do(ctx, http.MethodGet, path, nil, &mr); err != nil {
+		return "", fmt.Errorf("failed to fetch MR diff: %w", err)
+	}
+
+	var sb strings.Builder
+	for _, c := range mr.Changes {
+		fmt.Fprintf(&sb, "diff --git a/%s b/%s\n", c.OldPath, c.NewPath)
+		oldName, newName := "a/"+c.OldPath, "b/"+c.NewPath
+		if c.NewFile {
+			sb.WriteString("new file mode 100644\n")
+			oldName = "/dev/null"
+		}
+		if c.DeletedFile {
+			sb.WriteString("deleted file mode 100644\n")
+			newName = "/dev/null"
+		}
+		fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+		sb.WriteString(c.Diff)
+		if !strings.HasSuffix(c.Diff, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+// GetFileContent returns the raw file at ref. Contents at a commit SHA are
+// cached like GitHub's.
+func (s *GitLabService) GetFileContent(ctx context.Context, owner, repo, path, ref string) (string, error) {
+	key := "gitlab:" + s.BaseURL + "/" + owner + "/" + repo + "@" + ref + ":" + path
+	cacheable := isCommitSHA(ref)
+	if cacheable {
+		if content, ok := fileCache.get(key); ok {
+			return content, nil
+		}
+	}
+
+	var raw []byte
+	apiPath := fmt.Sprintf("/projects/%s/repository/files/%s/raw?ref=%s", projectPath(owner, repo), url.PathEscape(path), url.QueryEscape(ref))
+	if err := s.do(ctx, http.MethodGet, apiPath, nil, &raw); err != nil {
+		return "", fmt.Errorf("failed to get %s@%s: %w", path, ref, err)
+	}
+
+	if cacheable {
+		fileCache.put(key, string(raw))
+	}
+	return string(raw), nil
+}
+
+func (s *GitLabService) listNotes(ctx context.Context, owner, repo string, number int) ([]gitlabNote, error) {
+	var notes []gitlabNote
+	path := fmt.Sprintf("/projects/%s/merge_requests/%d/notes?per_page=100&sort=asc", projectPath(owner, repo), number)
+	if err := s.do(ctx, http.MethodGet, path, nil, &notes); err != nil {
+		return nil, fmt.Errorf("failed to list notes: %w", err)
+	}
+	return notes, nil
+}
+
+func (s *GitLabService) HasBotCommented(ctx context.Context, owner, repo string, number int) (bool, error) {
+	notes, err := s.listNotes(ctx, owner, repo, number)
+	if err != nil {
+		return false, err
+	}
+	for _, note := range notes {
+		if strings.Contains(note.Body, "## 🤖 AI Code Review") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *GitLabService) PostComment(ctx context.Context, owner, repo string, number int, body string) error {
+	path := fmt.Sprintf("/projects/%s/merge_requests/%d/notes", projectPath(owner, repo), number)
+	if err := s.do(ctx, http.MethodPost, path, map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to post comment: %w", err)
+	}
+	return nil
+}
+
+// UpsertComment edits the MR note containing marker, or creates it if there is none
+func (s *GitLabService) UpsertComment(ctx context.Context, owner, repo string, number int, marker, body string) error {
+	notes, err := s.listNotes(ctx, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		if strings.Contains(note.Body, marker) {
+			path := fmt.Sprintf("/projects/%s/merge_requests/%d/notes/%d", projectPath(owner, repo), number, note.ID)
+			if err := s.do(ctx, http.MethodPut, path, map[string]string{"body": body}, nil); err != nil {
+				return fmt.Errorf("failed to edit comment: %w", err)
+			}
+			return nil
+		}
+	}
+	return s.PostComment(ctx, owner, repo, number, body)
+}
+
+// PostInlineComments opens one diff discussion per comment. GitLab has no
+// review to hold the introduction, so body is not posted. Multi-line
+// comments are pinned to their last line.
+func (s *GitLabService) PostInlineComments(ctx context.Context, owner, repo string, number int, commitSHA, body string, comments []InlineComment) ([]PostedComment, error) {
+	var mr gitlabMergeRequest
+	if err := s.do(ctx, http.MethodGet, fmt.Sprintf("/projects/%s/merge_requests/%d", projectPath(owner, repo), number), nil, &mr); err != nil {
+		return nil, fmt.Errorf("failed to get merge request: %w", err)
+	}
+	headSHA := mr.DiffRefs.HeadSHA
+	if commitSHA != "" {
+		headSHA = commitSHA
+	}
+
+	var posted []PostedComment
+	path := fmt.Sprintf("/projects/%s/merge_requests/%d/discussions", projectPath(owner, repo), number)
+	for _, c := range comments {
+		oldPath := c.OldPath
+		if oldPath == "" {
+			oldPath = c.Path
+		}
+		request := map[string]interface{}{
+			"body": c.Body,
+			"position": map[string]interface{}{
+				"position_type": "text",
+				"base_sha":      mr.DiffRefs.BaseSHA,
+				"start_sha":     mr.DiffRefs.StartSHA,
+				"head_sha":      headSHA,
+				"old_path":      oldPath,
+				"new_path":      c.Path,
+				"new_line":      c.Line,
+			},
+		}
+		var discussion struct {
+			Notes []gitlabNote `json:"notes"`
+		}
+		if err := s.do(ctx, http.MethodPost, path, request, &discussion); err != nil {
+			return posted, fmt.Errorf("failed to create discussion on %s:%d: %w", c.Path, c.Line, err)
+		}
+		if len(discussion.Notes) > 0 {
+			posted = append(posted, PostedComment{ID: discussion.Notes[0].ID, Path: c.Path, Line: c.Line})
+		}
+	}
+	return posted, nil
+}
+
+// SetCommitStatus maps GitHub's states onto GitLab's pipeline states
+func (s *GitLabService) SetCommitStatus(ctx context.Context, owner, repo, sha, state, statusContext, description string) error {
+	switch state {
+	case "failure", "error":
+		state = "failed"
+	case "success", "pending":
+	default:
+		return fmt.Errorf("unknown commit state %q", state)
+	}
+
+	request := map[string]string{"state": state, "name": statusContext, "description": description}
+	if err := s.do(ctx, http.MethodPost, fmt.Sprintf("/projects/%s/statuses/%s", projectPath(owner, repo), sha), request, nil); err != nil {
+		return fmt.Errorf("failed to set commit status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,524 @@
+package service
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SourceStatic tags findings produced by the built-in analyzers rather than the model
+const SourceStatic = "static"
+
+// goCheck inspects one parsed file and reports findings by line
+type goCheck func(c *goCheckContext)
+
+type goCheckContext struct {
+	fset      *token.FileSet
+	file      *ast.File
+	path      string
+	goVersion string
+	// localErrFuncs are functions declared in this file whose last result is an error
+	localErrFuncs map[string]bool
+	issues        []ReviewIssue
+}
+
+func (c *goCheckContext) report(node ast.Node, typ, severity, message, suggestion string) {
+	c.issues = append(c.issues, ReviewIssue{
+		File:       c.path,
+		Line:       c.fset.Position(node.Pos()).Line,
+		Type:       typ,
+		Severity:   severity,
+		Message:    message,
+		Suggestion: suggestion,
+		Source:     SourceStatic,
+	})
+}
+
+var goChecks = []goCheck{
+	checkIgnoredErrors,
+	checkDeferInLoop,
+	checkGoroutineLoopVar,
+	checkSprintfSQL,
+	checkBackgroundContextInHandler,
+}
+
+// RunGoChecks runs the cheap, deterministic analyzers over the head version of
+// every changed Go file and keeps only findings on lines added by the diff.
+func RunGoChecks(files []FileChange, fetch FileFetcher) []ReviewIssue {
+	var issues []ReviewIssue
+	versions := make(map[string]string)
+
+	for _, f := range files {
+		if f.Language != "Go" {
+			continue
+		}
+		src, err := fetch(f.Path)
+		if err != nil {
+			continue
+		}
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, f.Path, src, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+
+		c := &goCheckContext{
+			fset:          fset,
+			file:          file,
+			path:          f.Path,
+			goVersion:     moduleGoVersion(path.Dir(f.Path), fetch, versions),
+			localErrFuncs: errorReturningFuncs(file),
+		}
+		for _, check := range goChecks {
+			check(c)
+		}
+
+		for _, issue := range c.issues {
+			if f.IsAdded(issue.Line) {
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues
+}
+
+// wellKnownErrFuncs return an error as their last result and are often ignored
+var wellKnownErrFuncs = map[string]bool{
+	"strconv.Atoi": true, "strconv.ParseInt": true, "strconv.ParseUint": true,
+	"strconv.ParseFloat": true, "strconv.ParseBool": true,
+	"json.Marshal": true, "json.MarshalIndent": true, "json.Unmarshal": true,
+	"os.Open": true, "os.Create": true, "os.ReadFile": true, "os.WriteFile": true,
+	"os.Remove": true, "os.RemoveAll": true, "os.MkdirAll": true,
+	"io.ReadAll": true, "url.Parse": true, "time.Parse": true, "time.ParseDuration": true,
+}
+
+// checkIgnoredErrors flags `_ = f()`, `v, _ := f()` and bare `f()` calls
+// whose dropped result is an error
+func checkIgnoredErrors(c *goCheckContext) {
+	ast.Inspect(c.file, func(n ast.Node) bool {
+		switch stmt := n.(type) {
+		case *ast.AssignStmt:
+			if len(stmt.Rhs) != 1 {
+				return true
+			}
+			call, ok := stmt.Rhs[0].(*ast.CallExpr)
+			if !ok || !c.returnsError(call) {
+				return true
+			}
+			if last, ok := stmt.Lhs[len(stmt.Lhs)-1].(*ast.Ident); ok && last.Name == "_" {
+				c.report(stmt, "bug", "medium",
+					"The error returned by "+callName(call)+" is discarded.",
+					"Handle the error, or return it to the caller.")
+			}
+		case *ast.ExprStmt:
+			call, ok := stmt.X.(*ast.CallExpr)
+			if ok && c.returnsError(call) {
+				c.report(stmt, "bug", "medium",
+					"The error returned by "+callName(call)+" is ignored.",
+					"Check the returned error.")
+			}
+		}
+		return true
+	})
+}
+
+func (c *goCheckContext) returnsError(call *ast.CallExpr) bool {
+	name := callName(call)
+	return wellKnownErrFuncs[name] || c.localErrFuncs[name]
+}
+
+// checkDeferInLoop flags defers that only run when the surrounding function
+// returns, piling up resources for every iteration
+func checkDeferInLoop(c *goCheckContext) {
+	var walk func(n ast.Node, inLoop bool)
+	walk = func(n ast.Node, inLoop bool) {
+		ast.Inspect(n, func(child ast.Node) bool {
+			if child == n {
+				return true
+			}
+			switch node := child.(type) {
+			case *ast.FuncLit:
+				// A closure has its own defer scope
+				walk(node.Body, false)
+				return false
+			case *ast.ForStmt:
+				walk(node.Body, true)
+				return false
+			case *ast.RangeStmt:
+				walk(node.Body, true)
+				return false
+			case *ast.DeferStmt:
+				if inLoop {
+					c.report(node, "performance", "medium",
+						"defer inside a loop only runs when the function returns, so resources stay open for every iteration.",
+						"Move the loop body into a function, or release the resource explicitly at the end of each iteration.")
+				}
+			}
+			return true
+		})
+	}
+	walk(c.file, false)
+}
+
+// checkGoroutineLoopVar flags `go func() { ... v ... }()` inside a loop that
+// captures the loop variable. Go 1.22 gave every iteration its own variable,
+// so modules on newer versions are skipped.
+func checkGoroutineLoopVar(c *goCheckContext) {
+	if c.goVersion != "" && versionAtLeast(c.goVersion, 1, 22) {
+		return
+	}
+
+	ast.Inspect(c.file, func(n ast.Node) bool {
+		var body *ast.BlockStmt
+		loopVars := make(map[string]bool)
+		switch loop := n.(type) {
+		case *ast.RangeStmt:
+			body = loop.Body
+			for _, e := range []ast.Expr{loop.Key, loop.Value} {
+				if id, ok := e.(*ast.Ident); ok && id.Name != "_" {
+					loopVars[id.Name] = true
+				}
+			}
+		case *ast.ForStmt:
+			body = loop.Body
+			if init, ok := loop.Init.(*ast.AssignStmt); ok {
+				for _, e := range init.Lhs {
+					if id, ok := e.(*ast.Ident); ok {
+						loopVars[id.Name] = true
+					}
+				}
+			}
+		default:
+			return true
+		}
+		if len(loopVars) == 0 {
+			return true
+		}
+
+		walkScoped(body, loopVars, func(inner ast.Node, live map[string]bool) bool {
+			goStmt, ok := inner.(*ast.GoStmt)
+			if !ok {
+				return true
+			}
+			lit, ok := goStmt.Call.Fun.(*ast.FuncLit)
+			if !ok {
+				return true
+			}
+			// Arguments are evaluated when the goroutine starts, which is the fix
+			captured := ""
+			walkScoped(lit, live, func(x ast.Node, live map[string]bool) bool {
+				if id, ok := x.(*ast.Ident); ok && live[id.Name] {
+					captured = id.Name
+				}
+				return captured == ""
+			})
+			if captured != "" {
+				c.report(goStmt, "bug", "high",
+					"The goroutine captures the loop variable `"+captured+"`; all goroutines may see the last value.",
+					"Pass `"+captured+"` as an argument to the function literal, or copy it inside the loop.")
+			}
+			return false
+		})
+		return true
+	})
+}
+
+// walkScoped walks n like ast.Inspect, handing visit the names of live that
+// still refer to the outer variables at each node: function parameters and
+// := or var redeclarations hide them from there on. Selected names (x.v)
+// and struct literal keys are not visited. visit returns false to skip a
+// subtree.
+func walkScoped(n ast.Node, live map[string]bool, visit func(ast.Node, map[string]bool) bool) {
+	if n == nil || !visit(n, live) {
+		return
+	}
+
+	switch x := n.(type) {
+	case *ast.FuncLit:
+		inner := copyNames(live)
+		for _, field := range x.Type.Params.List {
+			for _, name := range field.Names {
+				delete(inner, name.Name)
+			}
+		}
+		walkScoped(x.Body, inner, visit)
+	case *ast.SelectorExpr:
+		walkScoped(x.X, live, visit)
+	case *ast.KeyValueExpr:
+		if _, ok := x.Key.(*ast.Ident); !ok {
+			walkScoped(x.Key, live, visit)
+		}
+		walkScoped(x.Value, live, visit)
+	case *ast.BlockStmt:
+		walkScopedStmts(x.List, copyNames(live), visit)
+	case *ast.CaseClause:
+		for _, e := range x.List {
+			walkScoped(e, live, visit)
+		}
+		walkScopedStmts(x.Body, copyNames(live), visit)
+	case *ast.CommClause:
+		inner := copyNames(live)
+		if x.Comm != nil {
+			walkScopedStmts([]ast.Stmt{x.Comm}, inner, visit)
+		}
+		walkScopedStmts(x.Body, inner, visit)
+	case *ast.IfStmt:
+		inner := copyNames(live)
+		if x.Init != nil {
+			walkScopedStmts([]ast.Stmt{x.Init}, inner, visit)
+		}
+		walkScoped(x.Cond, inner, visit)
+		walkScoped(x.Body, inner, visit)
+		if x.Else != nil {
+			walkScoped(x.Else, inner, visit)
+		}
+	case *ast.ForStmt:
+		inner := copyNames(live)
+		if x.Init != nil {
+			walkScopedStmts([]ast.Stmt{x.Init}, inner, visit)
+		}
+		if x.Cond != nil {
+			walkScoped(x.Cond, inner, visit)
+		}
+		if x.Post != nil {
+			walkScoped(x.Post, inner, visit)
+		}
+		walkScoped(x.Body, inner, visit)
+	case *ast.RangeStmt:
+		walkScoped(x.X, live, visit)
+		inner := copyNames(live)
+		if x.Tok == token.DEFINE {
+			for _, e := range []ast.Expr{x.Key, x.Value} {
+				if id, ok := e.(*ast.Ident); ok {
+					delete(inner, id.Name)
+				}
+			}
+		}
+		walkScoped(x.Body, inner, visit)
+	case *ast.SwitchStmt:
+		inner := copyNames(live)
+		if x.Init != nil {
+			walkScopedStmts([]ast.Stmt{x.Init}, inner, visit)
+		}
+		if x.Tag != nil {
+			walkScoped(x.Tag, inner, visit)
+		}
+		walkScoped(x.Body, inner, visit)
+	case *ast.TypeSwitchStmt:
+		inner := copyNames(live)
+		if x.Init != nil {
+			walkScopedStmts([]ast.Stmt{x.Init}, inner, visit)
+		}
+		walkScopedStmts([]ast.Stmt{x.Assign}, inner, visit)
+		walkScoped(x.Body, inner, visit)
+	default:
+		ast.Inspect(n, func(child ast.Node) bool {
+			if child == n {
+				return true
+			}
+			if child != nil {
+				walkScoped(child, live, visit)
+			}
+			return false
+		})
+	}
+}
+
+// walkScopedStmts walks the statements of one block in order, so that a
+// redeclaration only hides a name from the statements after it. live is
+// updated in place.
+func walkScopedStmts(list []ast.Stmt, live map[string]bool, visit func(ast.Node, map[string]bool) bool) {
+	for _, stmt := range list {
+		switch s := stmt.(type) {
+		case *ast.AssignStmt:
+			if s.Tok != token.DEFINE {
+				break
+			}
+			if !visit(s, live) {
+				continue
+			}
+			// v := v reads the outer v before declaring the new one
+			for _, e := range s.Rhs {
+				walkScoped(e, live, visit)
+			}
+			for _, e := range s.Lhs {
+				if id, ok := e.(*ast.Ident); ok {
+					delete(live, id.Name)
+				}
+			}
+			continue
+		case *ast.DeclStmt:
+			gen, ok := s.Decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				break
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for _, e := range vs.Values {
+					walkScoped(e, live, visit)
+				}
+				for _, name := range vs.Names {
+					delete(live, name.Name)
+				}
+			}
+			continue
+		}
+		walkScoped(stmt, live, visit)
+	}
+}
+
+func copyNames(names map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(names))
+	for name := range names {
+		out[name] = true
+	}
+	return out
+}
+
+var sqlKeywords = regexp.MustCompile(`(?i)\b(select\s.+\sfrom|insert\s+into|update\s+\w+\s+set|delete\s+from)\b`)
+
+// checkSprintfSQL flags SQL statements assembled with fmt.Sprintf
+func checkSprintfSQL(c *goCheckContext) {
+	ast.Inspect(c.file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || callName(call) != "fmt.Sprintf" || len(call.Args) < 2 {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		format, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return true
+		}
+		if sqlKeywords.MatchString(strings.Join(strings.Fields(format), " ")) && strings.Contains(format, "%") {
+			c.report(call, "security", "high",
+				"SQL is built with fmt.Sprintf, which allows SQL injection.",
+				"Use query placeholders ($1, $2, ...) and pass the values as arguments.")
+		}
+		return true
+	})
+}
+
+// checkBackgroundContextInHandler flags context.Background()/TODO() inside
+// HTTP handlers, which drops cancellation when the client goes away
+func checkBackgroundContextInHandler(c *goCheckContext) {
+	for _, decl := range c.file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil || !isHandler(fn) {
+			continue
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if name := callName(call); name == "context.Background" || name == "context.TODO" {
+				c.report(call, "bug", "low",
+					name+"() in an HTTP handler ignores request cancellation and deadlines.",
+					"Use the request context (c.Request.Context() or r.Context()).")
+			}
+			return true
+		})
+	}
+}
+
+// isHandler reports whether fn takes a *gin.Context or an *http.Request
+func isHandler(fn *ast.FuncDecl) bool {
+	for _, field := range fn.Type.Params.List {
+		star, ok := field.Type.(*ast.StarExpr)
+		if !ok {
+			continue
+		}
+		switch typeName(star.X) {
+		case "gin.Context", "http.Request":
+			return true
+		}
+	}
+	return false
+}
+
+// errorReturningFuncs lists the functions in file whose last result is `error`
+func errorReturningFuncs(file *ast.File) map[string]bool {
+	funcs := make(map[string]bool)
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Type.Results == nil || fn.Recv != nil {
+			continue
+		}
+		results := fn.Type.Results.List
+		if id, ok := results[len(results)-1].Type.(*ast.Ident); ok && id.Name == "error" {
+			funcs[fn.Name.Name] = true
+		}
+	}
+	return funcs
+}
+
+// callName returns "pkg.Func" or "Func" for a call, "" for anything else
+func callName(call *ast.CallExpr) string {
+	return typeName(call.Fun)
+}
+
+func typeName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := e.X.(*ast.Ident); ok {
+			return pkg.Name + "." + e.Sel.Name
+		}
+	}
+	return ""
+}
+
+// moduleGoVersion finds the "go" directive of the nearest go.mod above dir
+func moduleGoVersion(dir string, fetch FileFetcher, cache map[string]string) string {
+	var visited []string
+	version := ""
+	for {
+		if v, ok := cache[dir]; ok {
+			version = v
+			break
+		}
+		visited = append(visited, dir)
+		if content, err := fetch(path.Join(dir, "go.mod")); err == nil {
+			for _, line := range strings.Split(content, "\n") {
+				fields := strings.Fields(line)
+				if len(fields) == 2 && fields[0] == "go" {
+					version = fields[1]
+					break
+				}
+			}
+			break
+		}
+		if dir == "." || dir == "/" || dir == "" {
+			break
+		}
+		dir = path.Dir(dir)
+	}
+
+	for _, d := range visited {
+		cache[d] = version
+	}
+	return version
+}
+
+// versionAtLeast compares a "1.22" or "1.22.3" style version
+func versionAtLeast(version string, major, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	gotMajor, err1 := strconv.Atoi(parts[0])
+	gotMinor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return gotMajor > major || (gotMajor == major && gotMinor >= minor)
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"sort"
+	"strings"
+)
+
+// MaxGoContextSize is the budget for Go symbol context in one prompt
+const MaxGoContextSize = 30000
+
+// DirLister returns the file names in a directory of the head commit
+type DirLister func(dir string) ([]string, error)
+
+// goPackageIndex holds the top-level declarations of one Go package
+type goPackageIndex struct {
+	types map[string]string // type name -> declaration source
+	funcs map[string]string // func name, or Type.Method -> signature
+	vars  map[string]string // package-level var -> its declared type name
+}
+
+// BuildGoContext extracts, for every changed Go file, the functions that
+// enclose the changed lines, the types those functions reference and the
+// signatures of functions they call from the same package, all taken from
+// the head version. This lets the model see the types behind the changed
+// lines instead of guessing them. Files in whole (from BuildFileContext)
+// already show their functions, so only the missing symbols are added.
+func BuildGoContext(files []FileChange, fetch FileFetcher, list DirLister, whole map[string]bool, budget int) string {
+	var sb strings.Builder
+	packages := make(map[string]*goPackageIndex)
+
+	for _, f := range files {
+		if f.Language != "Go" || strings.HasSuffix(f.Path, "_test.go") {
+			continue
+		}
+
+		src, err := fetch(f.Path)
+		if err != nil {
+			continue
+		}
+
+		dir := path.Dir(f.Path)
+		index, ok := packages[dir]
+		if !ok {
+			index = indexGoPackage(dir, fetch, list)
+			packages[dir] = index
+		}
+
+		section := goSymbolSection(f, src, index, !whole[f.Path])
+		if section == "" || sb.Len()+len(section) > budget {
+			continue
+		}
+		sb.WriteString(section)
+	}
+
+	return sb.String()
+}
+
+// goSymbolSection renders the symbol context for one changed file
+func goSymbolSection(f FileChange, src string, index *goPackageIndex, includeEnclosing bool) string {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, f.Path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return ""
+	}
+
+	var enclosing []*ast.FuncDecl
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		start := fset.Position(fn.Pos()).Line
+		end := fset.Position(fn.End()).Line
+		if touchesRange(f.Hunks, start, end) {
+			enclosing = append(enclosing, fn)
+		}
+	}
+	if len(enclosing) == 0 {
+		return ""
+	}
+
+	used := make(map[string]bool)
+	called := make(map[string]bool)
+	for _, fn := range enclosing {
+		collectGoReferences(fn, index, used, called)
+	}
+
+	var sb strings.Builder
+	if includeEnclosing {
+		sb.WriteString("Enclosing functions:\n")
+		for _, fn := range enclosing {
+			start := fset.Position(fn.Pos()).Line
+			sb.WriteString(numberLines(strings.Split(src, "\n"), start, fset.Position(fn.End()).Line))
+			sb.WriteString("\n")
+		}
+	}
+
+	if types := lookupSorted(index.types, used); len(types) > 0 {
+		sb.WriteString("Referenced types:\n")
+		for _, decl := range types {
+			sb.WriteString(decl + "\n\n")
+		}
+	}
+
+	if funcs := lookupSorted(index.funcs, called); len(funcs) > 0 {
+		sb.WriteString("Called functions in the same package:\n")
+		for _, sig := range funcs {
+			sb.WriteString(sig + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	if sb.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("### GO SYMBOLS: %s (package %s, read-only)\n%s", f.Path, file.Name.Name, sb.String())
+}
+
+// indexGoPackage parses every non-test Go file in dir at head
+func indexGoPackage(dir string, fetch FileFetcher, list DirLister) *goPackageIndex {
+	index := &goPackageIndex{types: make(map[string]string), funcs: make(map[string]string), vars: make(map[string]string)}
+
+	names, err := list(dir)
+	if err != nil {
+		return index
+	}
+
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		filePath := path.Join(dir, name)
+		src, err := fetch(filePath)
+		if err != nil {
+			continue
+		}
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, filePath, src, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						index.types[s.Name.Name] = "type " + sourceOf(fset, src, s)
+					case *ast.ValueSpec:
+						if d.Tok != token.VAR || s.Type == nil {
+							continue
+						}
+						for _, name := range s.Names {
+							index.vars[name.Name] = baseTypeName(s.Type)
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				index.funcs[goFuncKey(d)] = funcSignature(fset, d)
+			}
+		}
+	}
+	return index
+}
+
+// collectGoReferences records identifiers used in fn and the functions it
+// calls that the package index can resolve: plain calls, and methods called
+// on the receiver, a package-level variable or a type (T.Method). Other
+// x.Method() calls can't be typed without type checking, so a same-named
+// method of an unrelated type is not reported.
+func collectGoReferences(fn *ast.FuncDecl, index *goPackageIndex, used, called map[string]bool) {
+	// Identifiers whose type is known: package-level vars, unless a
+	// parameter shadows them, and the receiver
+	typed := make(map[string]string)
+	for name, typ := range index.vars {
+		typed[name] = typ
+	}
+	for _, field := range fn.Type.Params.List {
+		for _, name := range field.Names {
+			delete(typed, name.Name)
+		}
+	}
+	if fn.Recv != nil && len(fn.Recv.List) > 0 {
+		for _, name := range fn.Recv.List[0].Names {
+			typed[name.Name] = baseTypeName(fn.Recv.List[0].Type)
+		}
+	}
+
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.Ident:
+			used[node.Name] = true
+		case *ast.CallExpr:
+			switch fun := node.Fun.(type) {
+			case *ast.Ident:
+				called[fun.Name] = true
+			case *ast.SelectorExpr:
+				x, ok := fun.X.(*ast.Ident)
+				if !ok {
+					break
+				}
+				if typ := typed[x.Name]; typ != "" {
+					called[typ+"."+fun.Sel.Name] = true
+				} else if _, isType := index.types[x.Name]; isType {
+					called[x.Name+"."+fun.Sel.Name] = true
+				}
+			}
+		}
+		return true
+	})
+	delete(called, goFuncKey(fn))
+}
+
+// goFuncKey names a function in the package index: Name, or Type.Name for methods
+func goFuncKey(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	return baseTypeName(fn.Recv.List[0].Type) + "." + fn.Name.Name
+}
+
+// baseTypeName strips pointers and type arguments: *List[T] is List
+func baseTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return baseTypeName(t.X)
+	case *ast.IndexExpr:
+		return baseTypeName(t.X)
+	case *ast.IndexListExpr:
+		return baseTypeName(t.X)
+	}
+	return ""
+}
+
+// funcSignature prints a function declaration without its body
+func funcSignature(fset *token.FileSet, fn *ast.FuncDecl) string {
+	stripped := *fn
+	stripped.Body = nil
+	stripped.Doc = nil
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, &stripped); err != nil {
+		return "func " + fn.Name.Name + "(...)"
+	}
+	return buf.String()
+}
+
+func sourceOf(fset *token.FileSet, src string, node ast.Node) string {
+	start := fset.Position(node.Pos()).Offset
+	end := fset.Position(node.End()).Offset
+	if start < 0 || end > len(src) || start >= end {
+		return ""
+	}
+	return src[start:end]
+}
+
+// touchesRange reports whether any hunk overlaps lines start..end
+func touchesRange(hunks []Hunk, start, end int) bool {
+	for _, h := range hunks {
+		if h.NewStart <= end && h.NewStart+h.NewLines-1 >= start {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupSorted(decls map[string]string, names map[string]bool) []string {
+	var keys []string
+	for name := range names {
+		if _, ok := decls[name]; ok {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, decls[k])
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PRSummary is the walkthrough written for a pull request, separate from the findings
+type PRSummary struct {
+	Description      string        `json:"description"`
+	Files            []FileSummary `json:"files"`
+	RiskAreas        []string      `json:"risk_areas"`
+	TestingChecklist []string      `json:"testing_checklist"`
+}
+
+// FileSummary is the one-line explanation of a single changed file
+type FileSummary struct {
+	Path    string `json:"path"`
+	Summary string `json:"summary"`
+}
+
+// maxSummaryInput keeps the walkthrough prompt small; the model only needs
+// enough of each file to say what changed, not to review it line by line.
+const maxSummaryInput = 60000
+
+// SummarizePR asks the model for a description and file-by-file walkthrough
+// of the structured diff. It uses its own prompt so that summarising never
+// competes with finding issues.
+func (s *AIService) SummarizePR(ctx context.Context, files []FileChange) (*PRSummary, error) {
+	if s.Client == nil {
+		return nil, fmt.Errorf("AI Client not initialized. Check GEMINI_API_KEY")
+	}
+
+	var changes strings.Builder
+	for _, f := range files {
+		content := f.Content
+		if remaining := maxSummaryInput - changes.Len(); len(content) > remaining {
+			if remaining <= 0 {
+				fmt.Fprintf(&changes, "### FILE: %s (%s)\n[diff omitted for size]\n\n", f.Path, f.Language)
+				continue
+			}
+			content = content[:remaining] + "\n... [TRUNCATED] ..."
+		}
+		fmt.Fprintf(&changes, "### FILE: %s (%s)\n%s\n\n", f.Path, f.Language, content)
+	}
+
+	prompt := fmt.Sprintf(`
+	You are a Senior Engineer writing the description of a Pull Request for your reviewers.
+	Do NOT look for bugs; only explain what the change does.
+
+	STRICT OUTPUT FORMAT:
+	You must respond ONLY with a valid JSON object. Do not use markdown fences.
+	{
+		"description": "2-4 sentence high-level description of the change",
+		"files": [ { "path": "path/to/file.ext", "summary": "One line describing the change in this file" } ],
+		"risk_areas": [ "Parts of the change that deserve careful review, and why" ],
+		"testing_checklist": [ "Concrete things a reviewer or QA should test" ]
+	}
+
+	Include every file listed below in "files", in the same order.
+
+	%s
+
+	CHANGED FILES (DIFF PER FILE):
+	%s
+	`, untrustedNotice, untrustedBlock("CHANGED_FILES", changes.String()))
+
+	raw, err := s.generate(ctx, prompt, "{}")
+	if err != nil {
+		return nil, err
+	}
+
+	var summary PRSummary
+	if err := json.Unmarshal([]byte(stripJSONFence(raw)), &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse PR summary: %w", err)
+	}
+	return &summary, nil
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaxReviewDiffSize is the part of the diff the review prompt can hold next
+// to the file context. Bigger PRs are reviewed by priority.
+const MaxReviewDiffSize = 120000
+
+// sensitivePath matches code where a missed bug is expensive
+var sensitivePath = regexp.MustCompile(`(?i)(auth|login|session|token|passw|crypto|secret|permission|acl|oauth|jwt|sql|query|migration|database|(^|/)db/|repositor|handler|controller|middleware|webhook|payment|billing)`)
+
+var testPath = regexp.MustCompile(`(?i)(_test\.go$|\.(test|spec)\.[jt]sx?$|(^|/)(tests?|__tests__|testdata|fixtures?)/|(^|/)test_[^/]+\.py$)`)
+
+// FileScore is how much a changed file deserves a review slot
+type FileScore struct {
+	Path    string
+	Score   float64
+	Size    int
+	Reasons []string
+}
+
+// ScoreFile rates one file diff by churn, path, language, test vs production
+// code and the repository's hotspot globs
+func ScoreFile(path, fileDiff string, hotspots []string) FileScore {
+	s := FileScore{Path: path, Size: len(fileDiff)}
+
+	churn := 0
+	for _, line := range strings.Split(fileDiff, "\n") {
+		if (strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++")) ||
+			(strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "---")) {
+			churn++
+		}
+	}
+	s.Score += math.Log2(float64(1 + churn))
+	s.Reasons = append(s.Reasons, fmt.Sprintf("%d changed lines", churn))
+
+	if sensitivePath.MatchString(path) {
+		s.Score += 4
+		s.Reasons = append(s.Reasons, "sensitive path")
+	}
+
+	switch detectLanguage(path) {
+	case "Go", "Python", "Java", "JavaScript/TypeScript":
+		s.Score += 2
+	case "Web":
+		s.Score++
+	case "Markdown":
+		s.Score -= 2
+		s.Reasons = append(s.Reasons, "documentation")
+	}
+
+	if testPath.MatchString(path) {
+		s.Score -= 3
+		s.Reasons = append(s.Reasons, "test code")
+	}
+
+	for _, pattern := range hotspots {
+		if MatchGlob(pattern, path) {
+			s.Score += 5
+			s.Reasons = append(s.Reasons, fmt.Sprintf("hotspot `%s`", pattern))
+			break
+		}
+	}
+	return s
+}
+
+// PrioritizeDiff keeps rawDiff as is when it fits the budget. Otherwise it
+// keeps the highest-scoring files that fit, in their original order, and
+// returns the others as omitted.
+func PrioritizeDiff(rawDiff, hotspotPatterns string, budget int) (string, []SkippedFile) {
+	if len(rawDiff) <= budget {
+		return rawDiff, nil
+	}
+
+	hotspots := splitLines(hotspotPatterns)
+	var scores []FileScore
+	for _, rawFile := range strings.Split(rawDiff, "diff --git ") {
+		path := extractFilePath(rawFile)
+		if path == "" {
+			continue
+		}
+		scores = append(scores, ScoreFile(path, "diff --git "+rawFile, hotspots))
+	}
+
+	ranked := append([]FileScore{}, scores...)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+
+	keep := make(map[string]bool)
+	used := 0
+	var omitted []SkippedFile
+	for _, s := range ranked {
+		if used+s.Size > budget {
+			omitted = append(omitted, SkippedFile{
+				Path:   s.Path,
+				Reason: fmt.Sprintf("priority %.1f (%s)", s.Score, strings.Join(s.Reasons, ", ")),
+			})
+			continue
+		}
+		keep[s.Path] = true
+		used += s.Size
+	}
+
+	return filterDiff(rawDiff, func(path string) bool { return keep[path] }), omitted
+}
+
+// ExcludeFiles drops the skipped files from files
+func ExcludeFiles(files []FileChange, skipped []SkippedFile) []FileChange {
+	if len(skipped) == 0 {
+		return files
+	}
+	drop := make(map[string]bool)
+	for _, s := range skipped {
+		drop[s.Path] = true
+	}
+	var out []FileChange
+	for _, f := range files {
+		if !drop[f.Path] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
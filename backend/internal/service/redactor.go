@@ -0,0 +1,194 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var emailPattern = regexp.MustCompile(`\b[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}\b`)
+
+// privateKeyBlock spans the whole key, not just the BEGIN line the scanner
+// reports. Its body is redacted line by line so diff line numbers don't move.
+var privateKeyBlock = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY(?: BLOCK)?-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY(?: BLOCK)?-----`)
+
+var keyMaterial = regexp.MustCompile(`[A-Za-z0-9+/=]{16,}`)
+
+var placeholderPattern = regexp.MustCompile(`\[REDACTED_(?:SECRET|EMAIL|SENSITIVE)_\d+\]`)
+
+// Redactor replaces secrets, email addresses and configured sensitive
+// patterns with stable placeholders before text is sent to an LLM, and maps
+// the placeholders back when the model's answer is rendered. The same value
+// always gets the same placeholder, so the model can still tell two
+// occurrences of one key apart from two different keys.
+type Redactor struct {
+	scanner   *SecretScanner
+	sensitive []*regexp.Regexp
+
+	placeholders map[string]string // original value -> placeholder
+	originals    map[string]string // placeholder -> original value
+	secrets      map[string]bool   // placeholders that hide credentials
+	counters     map[string]int
+}
+
+// NewRedactor builds a redactor from the repository's secret rules and its
+// extra sensitive patterns, one regular expression per line
+func NewRedactor(scanner *SecretScanner, sensitivePatterns string) (*Redactor, error) {
+	r := &Redactor{
+		scanner:      scanner,
+		placeholders: make(map[string]string),
+		originals:    make(map[string]string),
+		secrets:      make(map[string]bool),
+		counters:     make(map[string]int),
+	}
+	for _, expr := range splitLines(sensitivePatterns) {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sensitive pattern %q: %w", expr, err)
+		}
+		r.sensitive = append(r.sensitive, re)
+	}
+	return r, nil
+}
+
+// Redact returns text with every detected value replaced by its placeholder
+func (r *Redactor) Redact(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+
+	text = privateKeyBlock.ReplaceAllStringFunc(text, func(block string) string {
+		return keyMaterial.ReplaceAllStringFunc(block, func(value string) string {
+			return r.placeholderFor(value, "SECRET")
+		})
+	})
+	if r.scanner != nil {
+		for _, rule := range r.scanner.Rules {
+			text = r.replace(text, rule.Pattern, rule.Group, "SECRET", func(value string) bool {
+				if rule.MinEntropy > 0 && shannonEntropy(value) < rule.MinEntropy {
+					return false
+				}
+				return !r.scanner.allowed("", value, value)
+			})
+		}
+	}
+	text = r.replace(text, emailPattern, 0, "EMAIL", nil)
+	for _, re := range r.sensitive {
+		text = r.replace(text, re, 0, "SENSITIVE", nil)
+	}
+	return text
+}
+
+// RedactFiles returns copies of files with their diff content redacted
+func (r *Redactor) RedactFiles(files []FileChange) []FileChange {
+	redacted := make([]FileChange, len(files))
+	for i, f := range files {
+		f.Content = r.Redact(f.Content)
+		redacted[i] = f
+	}
+	return redacted
+}
+
+// Restore maps placeholders in the model's output back. Credentials are
+// restored in masked form only, so a review comment never republishes them.
+func (r *Redactor) Restore(text string) string {
+	if r == nil || len(r.originals) == 0 {
+		return text
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		original, ok := r.originals[placeholder]
+		if !ok {
+			return placeholder
+		}
+		if r.secrets[placeholder] {
+			return MaskSecret(original)
+		}
+		return original
+	})
+}
+
+// RestoreIssues maps placeholders back in findings. Suggested code that
+// still refers to a credential is dropped: applying it would either leak the
+// secret or commit the masked value.
+func (r *Redactor) RestoreIssues(issues []ReviewIssue) []ReviewIssue {
+	for i := range issues {
+		issues[i].Message = r.Restore(issues[i].Message)
+		issues[i].Suggestion = r.Restore(issues[i].Suggestion)
+		if r.hidesSecret(issues[i].SuggestedCode) {
+			issues[i].StartLine, issues[i].EndLine, issues[i].SuggestedCode = 0, 0, ""
+			continue
+		}
+		issues[i].SuggestedCode = r.Restore(issues[i].SuggestedCode)
+	}
+	return issues
+}
+
+func (r *Redactor) hidesSecret(text string) bool {
+	for _, placeholder := range placeholderPattern.FindAllString(text, -1) {
+		if r.secrets[placeholder] {
+			return true
+		}
+	}
+	return false
+}
+
+// replace swaps every accepted match (or its capture group) for a placeholder
+func (r *Redactor) replace(text string, re *regexp.Regexp, group int, kind string, accept func(string) bool) string {
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	type span struct{ start, end int }
+	var spans []span
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if group > 0 && 2*group+1 < len(m) && m[2*group] >= 0 {
+			start, end = m[2*group], m[2*group+1]
+		}
+		value := text[start:end]
+		if placeholderPattern.MatchString(value) || (accept != nil && !accept(value)) {
+			continue
+		}
+		spans = append(spans, span{start, end})
+	}
+	// Replace from the end so earlier offsets stay valid
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+
+	for _, sp := range spans {
+		text = text[:sp.start] + r.placeholderFor(text[sp.start:sp.end], kind) + text[sp.end:]
+	}
+	return text
+}
+
+func (r *Redactor) placeholderFor(value, kind string) string {
+	if placeholder, ok := r.placeholders[value]; ok {
+		return placeholder
+	}
+	r.counters[kind]++
+	placeholder := fmt.Sprintf("[REDACTED_%s_%d]", kind, r.counters[kind])
+	r.placeholders[value] = placeholder
+	r.originals[placeholder] = value
+	if kind == "SECRET" {
+		r.secrets[placeholder] = true
+	}
+	return placeholder
+}
+
+// String summarises what was redacted, for logs
+func (r *Redactor) String() string {
+	if r == nil {
+		return "redactor(nil)"
+	}
+	var parts []string
+	for _, kind := range []string{"SECRET", "EMAIL", "SENSITIVE"} {
+		if n := r.counters[kind]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, strings.ToLower(kind)))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing redacted"
+	}
+	return strings.Join(parts, ", ") + " value(s) redacted"
+}
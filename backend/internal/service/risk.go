@@ -0,0 +1,117 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// RiskInput is what a PR's risk score is computed from
+type RiskInput struct {
+	Files        []FileChange
+	Issues       []ReviewIssue
+	Dependencies []DependencyChange
+}
+
+// RiskAssessment is a 0-100 score and the factors that contributed to it
+type RiskAssessment struct {
+	Score   int
+	Factors []string
+}
+
+// AssessRisk adds up capped points for size, sensitive paths, findings,
+// dependency changes and production code changed without a matching test
+func AssessRisk(in RiskInput) RiskAssessment {
+	var r RiskAssessment
+	add := func(points, limit int, factor string) {
+		if points > limit {
+			points = limit
+		}
+		if points > 0 {
+			r.Score += points
+			r.Factors = append(r.Factors, fmt.Sprintf("%s (+%d)", factor, points))
+		}
+	}
+
+	churn, sensitive := 0, 0
+	for _, f := range in.Files {
+		for _, h := range f.Hunks {
+			churn += len(h.AddedLines)
+		}
+		if sensitivePath.MatchString(f.Path) && !testPath.MatchString(f.Path) {
+			sensitive++
+		}
+	}
+	add(churn/20, 20, fmt.Sprintf("%d added lines", churn))
+	add(sensitive*5, 20, fmt.Sprintf("%d sensitive file(s)", sensitive))
+
+	high, medium, low := 0, 0, 0
+	for _, issue := range in.Issues {
+		switch strings.ToLower(issue.Severity) {
+		case "high", "critical":
+			high++
+		case "medium":
+			medium++
+		default:
+			low++
+		}
+	}
+	add(high*10+medium*4+low, 35, fmt.Sprintf("%d high / %d medium / %d low finding(s)", high, medium, low))
+
+	direct, flagged := 0, 0
+	for _, d := range in.Dependencies {
+		if d.Direct {
+			direct++
+		}
+		if d.Flagged() {
+			flagged++
+		}
+	}
+	add(direct*2+flagged*8, 15, fmt.Sprintf("%d direct dependency change(s), %d flagged", direct, flagged))
+
+	if untested, total := untestedFiles(in.Files); untested > 0 {
+		add(untested*10/total, 10, fmt.Sprintf("%d of %d changed source file(s) without a test change", untested, total))
+	}
+
+	if r.Score > 100 {
+		r.Score = 100
+	}
+	return r
+}
+
+// untestedFiles counts changed source files whose test file (foo_test.go,
+// foo.test.ts, test_foo.py, ...) is not part of the change
+func untestedFiles(files []FileChange) (untested, total int) {
+	changedTests := make(map[string]bool)
+	for _, f := range files {
+		if testPath.MatchString(f.Path) {
+			changedTests[testSubject(f.Path)] = true
+		}
+	}
+	for _, f := range files {
+		switch detectLanguage(f.Path) {
+		case "Go", "Python", "Java", "JavaScript/TypeScript":
+		default:
+			continue
+		}
+		if testPath.MatchString(f.Path) {
+			continue
+		}
+		total++
+		if !changedTests[testSubject(f.Path)] {
+			untested++
+		}
+	}
+	return untested, total
+}
+
+// testSubject reduces a source or test path to the base name they share
+func testSubject(filePath string) string {
+	name := path.Base(filePath)
+	name = strings.TrimPrefix(name, "test_")
+	name = strings.TrimSuffix(name, path.Ext(name))
+	for _, suffix := range []string{"_test", ".test", ".spec", "Test"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	return name
+}
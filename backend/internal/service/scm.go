@@ -0,0 +1,45 @@
+package service
+
+import "context"
+
+// Code hosts a repository can live on
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea" // Also Forgejo
+)
+
+// SCMProvider is what a review needs from the code host. "number" is the PR
+// number on GitHub and Gitea and the merge request IID on GitLab; on GitLab
+// "owner" is the namespace path and "repo" the project path.
+type SCMProvider interface {
+	GetPullRequestDiff(ctx context.Context, owner, repo string, number int) (string, error)
+	GetFileContent(ctx context.Context, owner, repo, path, ref string) (string, error)
+	HasBotCommented(ctx context.Context, owner, repo string, number int) (bool, error)
+	PostComment(ctx context.Context, owner, repo string, number int, body string) error
+	UpsertComment(ctx context.Context, owner, repo string, number int, marker, body string) error
+	// PostInlineComments pins comments to lines of commitSHA. body introduces
+	// them where the host groups comments into one review.
+	PostInlineComments(ctx context.Context, owner, repo string, number int, commitSHA, body string, comments []InlineComment) ([]PostedComment, error)
+	// SetCommitStatus takes GitHub's states: success, failure, pending, error
+	SetCommitStatus(ctx context.Context, owner, repo, sha, state, statusContext, description string) error
+}
+
+// InlineComment is a comment on the new side of the diff. StartLine is set
+// for multi-line comments.
+type InlineComment struct {
+	Path      string
+	OldPath   string // Path before a rename; hosts that pin comments to both sides need it
+	Line      int
+	StartLine int
+	Body      string
+}
+
+// PostedComment is an inline comment as the host created it
+type PostedComment struct {
+	ID   int64
+	Path string
+	Line int
+}
+
+var _ SCMProvider = (*GitHubService)(nil)
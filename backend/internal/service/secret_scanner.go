@@ -0,0 +1,206 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// SourceSecretScan tags findings produced by the secret scanner
+const SourceSecretScan = "secrets"
+
+// SecretRule matches one kind of credential. When MinEntropy is set the
+// captured value must also look random enough, which keeps placeholders like
+// "changeme" or "${API_KEY}" from being reported.
+type SecretRule struct {
+	ID          string
+	Description string
+	Pattern     *regexp.Regexp
+	Group       int // Capture group holding the secret, 0 for the whole match
+	MinEntropy  float64
+}
+
+// DefaultSecretRules cover the credentials we see leaked most often
+var DefaultSecretRules = []SecretRule{
+	{ID: "aws-access-key", Description: "AWS access key ID", Pattern: regexp.MustCompile(`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`), Group: 1},
+	{ID: "aws-secret-key", Description: "AWS secret access key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key\W{0,5}([A-Za-z0-9/+=]{40})`), Group: 1, MinEntropy: 4.0},
+	{ID: "gcp-api-key", Description: "Google API key", Pattern: regexp.MustCompile(`\b(AIza[0-9A-Za-z\-_]{35})\b`), Group: 1},
+	{ID: "github-token", Description: "GitHub token", Pattern: regexp.MustCompile(`\b((?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`), Group: 1},
+	{ID: "slack-token", Description: "Slack token", Pattern: regexp.MustCompile(`\b(xox[baprs]-[0-9A-Za-z-]{10,})\b`), Group: 1},
+	{ID: "stripe-key", Description: "Stripe secret key", Pattern: regexp.MustCompile(`\b((?:sk|rk)_live_[0-9A-Za-z]{20,})\b`), Group: 1},
+	{ID: "private-key", Description: "Private key block", Pattern: regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`)},
+	{ID: "jwt", Description: "JSON Web Token", Pattern: regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})\b`), Group: 1},
+	{ID: "generic-secret", Description: "High-entropy secret", Pattern: regexp.MustCompile(`(?i)(?:secret|token|passw(?:or)?d|api_?key|access_?key|client_?secret)\w*["']?\s*[:=]\s*["']?([A-Za-z0-9/+=_\-.]{16,})`), Group: 1, MinEntropy: 3.5},
+}
+
+// SecretFinding is one credential found on an added line
+type SecretFinding struct {
+	RuleID      string
+	Description string
+	File        string
+	Line        int
+	Masked      string
+	secret      string
+}
+
+// SecretScanner checks the added lines of a diff against a rule set
+type SecretScanner struct {
+	Rules     []SecretRule
+	Allowlist []*regexp.Regexp
+}
+
+// NewSecretScanner builds a scanner from the default rules plus a
+// repository's extra patterns and allowlist, one regular expression per line
+func NewSecretScanner(extraPatterns, allowlist string) (*SecretScanner, error) {
+	scanner := &SecretScanner{Rules: append([]SecretRule{}, DefaultSecretRules...)}
+
+	for i, expr := range splitLines(extraPatterns) {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret pattern %q: %w", expr, err)
+		}
+		scanner.Rules = append(scanner.Rules, SecretRule{
+			ID:          fmt.Sprintf("custom-%d", i+1),
+			Description: "Secret matching a repository pattern",
+			Pattern:     re,
+		})
+	}
+
+	for _, expr := range splitLines(allowlist) {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret allowlist entry %q: %w", expr, err)
+		}
+		scanner.Allowlist = append(scanner.Allowlist, re)
+	}
+	return scanner, nil
+}
+
+// Scan reports every secret on the lines added by the diff. An allowlist
+// entry suppresses a hit when it matches the secret, the line or the path.
+func (s *SecretScanner) Scan(files []FileChange) []SecretFinding {
+	var findings []SecretFinding
+	for _, f := range files {
+		for _, h := range f.Hunks {
+			for i, text := range h.AddedText {
+				for _, rule := range s.Rules {
+					for _, m := range rule.Pattern.FindAllStringSubmatch(text, -1) {
+						secret := m[0]
+						if rule.Group > 0 && rule.Group < len(m) {
+							secret = m[rule.Group]
+						}
+						if rule.MinEntropy > 0 && shannonEntropy(secret) < rule.MinEntropy {
+							continue
+						}
+						if s.allowed(f.Path, text, secret) {
+							continue
+						}
+						findings = append(findings, SecretFinding{
+							RuleID:      rule.ID,
+							Description: rule.Description,
+							File:        f.Path,
+							Line:        h.AddedLines[i],
+							Masked:      MaskSecret(secret),
+							secret:      secret,
+						})
+					}
+				}
+			}
+		}
+	}
+	return dedupeSecretFindings(findings)
+}
+
+func (s *SecretScanner) allowed(path, line, secret string) bool {
+	for _, re := range s.Allowlist {
+		if re.MatchString(secret) || re.MatchString(line) || re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// SecretIssues turns secret hits into high-severity security findings
+func SecretIssues(findings []SecretFinding) []ReviewIssue {
+	var issues []ReviewIssue
+	for _, f := range findings {
+		issues = append(issues, ReviewIssue{
+			File:       f.File,
+			Line:       f.Line,
+			Type:       "security",
+			Severity:   "high",
+			Message:    fmt.Sprintf("%s committed to the repository: `%s`", f.Description, f.Masked),
+			Suggestion: "Revoke and rotate this credential, remove it from the branch history, and load it from the environment or a secret manager instead.",
+			Source:     SourceSecretScan,
+		})
+	}
+	return issues
+}
+
+// MaskSecret keeps just enough of a secret to recognise it
+func MaskSecret(secret string) string {
+	if strings.HasPrefix(secret, "-----BEGIN") {
+		return secret
+	}
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", 8) + secret[len(secret)-2:]
+}
+
+// dedupeSecretFindings drops repeated hits, and the generic rule's hit when a
+// specific rule already reported the token it wraps (e.g. TOKEN=ghp_...)
+func dedupeSecretFindings(findings []SecretFinding) []SecretFinding {
+	seen := make(map[string]bool)
+	var out []SecretFinding
+	for _, f := range findings {
+		key := fmt.Sprintf("%s:%d:%s", f.File, f.Line, f.secret)
+		if seen[key] || (f.RuleID == "generic-secret" && coveredBySpecificRule(f, findings)) {
+			continue
+		}
+		seen[key] = true
+		out = append(out, f)
+	}
+	return out
+}
+
+func coveredBySpecificRule(generic SecretFinding, findings []SecretFinding) bool {
+	for _, other := range findings {
+		if other.RuleID != generic.RuleID && other.File == generic.File && other.Line == generic.Line && strings.Contains(generic.secret, other.secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// shannonEntropy returns the bits of entropy per character of s
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var entropy float64
+	n := float64(len([]rune(s)))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// splitLines returns the non-empty, non-comment lines of a setting
+func splitLines(setting string) []string {
+	var lines []string
+	for _, line := range strings.Split(setting, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,214 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/hibiken/asynq"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/database"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+)
+
+// HandleAutofixTask applies every accepted suggestion of the latest review on
+// a new branch cut from the PR head, opens a follow-up PR into the PR's branch
+// and reports what was applied and what was left out on the original PR.
+func HandleAutofixTask(ctx context.Context, t *asynq.Task) error {
+	var payload AutofixPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	log.Printf("Processing Autofix for: %s/%s PR #%d", payload.RepoOwner, payload.RepoName, payload.PRNumber)
+
+	if installationSuspended(ctx, payload.InstallationID) {
+		return nil
+	}
+
+	ghService, err := service.NewGitHubServiceFor(ctx, payload.RepoOwner, payload.RepoName, payload.InstallationID)
+	if err != nil {
+		return err
+	}
+	report := func(body string) error {
+		return ghService.PostComment(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber, "## 🛠️ AI Autofix\n\n"+body+"\n\n"+service.BotCommentMarker)
+	}
+
+	pr, err := ghService.GetPullRequest(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber)
+	if err != nil {
+		return err
+	}
+	if pr.GetHead().GetRepo().GetFullName() != pr.GetBase().GetRepo().GetFullName() {
+		return report("This PR comes from a fork, so I can't push a fix branch for it.")
+	}
+	headSHA := pr.GetHead().GetSHA()
+
+	repo, err := repository.NewRepoRepository(database.Pool).GetRepositoryByOwnerName(ctx, payload.RepoOwner, payload.RepoName)
+	if err != nil {
+		return err
+	}
+	if repo == nil {
+		return report("This repository is not registered, so there are no stored findings to apply.")
+	}
+
+	review, err := repository.NewReviewRepository(database.Pool).GetLatestReview(ctx, repo.ID, payload.PRNumber)
+	if err != nil {
+		return err
+	}
+	if review == nil {
+		return report("There is no review for this PR yet.")
+	}
+	if review.CommitSHA != headSHA {
+		// Reviews of later pushes are skipped once the bot has commented,
+		// so the new head only gets one when it is forced
+		next := "I've queued a review of the new commits; ask again once it is posted."
+		if err := queueForcedReview(payload, pr); err != nil {
+			log.Printf(" Failed to queue a review of %.7s: %v", headSHA, err)
+			next = "Add the `ai-review` label to get the new commits reviewed, then ask again."
+		}
+		return report(fmt.Sprintf("The latest review was for `%.7s`, but the PR is now at `%.7s`. %s", review.CommitSHA, headSHA, next))
+	}
+
+	issues, err := repository.NewIssueRepository(database.Pool).ListFixableIssues(ctx, review.ID)
+	if err != nil {
+		return err
+	}
+	if len(issues) == 0 {
+		return report("None of the open findings on this PR come with suggested code.")
+	}
+
+	var fixes []service.Fix
+	for _, issue := range issues {
+		fixes = append(fixes, service.Fix{
+			IssueID:   issue.ID,
+			Path:      issue.FilePath,
+			StartLine: issue.StartLine,
+			EndLine:   issue.EndLine,
+			Code:      issue.SuggestedCode,
+		})
+	}
+	accepted, skipped := service.SelectFixes(fixes)
+
+	byPath := make(map[string][]service.Fix)
+	var paths []string
+	for _, fix := range accepted {
+		if _, ok := byPath[fix.Path]; !ok {
+			paths = append(paths, fix.Path)
+		}
+		byPath[fix.Path] = append(byPath[fix.Path], fix)
+	}
+
+	files := make(map[string]string)
+	var applied, failed []service.Fix
+	for _, path := range paths {
+		content, err := ghService.GetFileContent(ctx, payload.RepoOwner, payload.RepoName, path, headSHA)
+		if err != nil {
+			log.Printf(" Autofix could not read %s: %v", path, err)
+			failed = append(failed, byPath[path]...)
+			continue
+		}
+		updated, ok, bad := service.ApplyFixes(content, byPath[path])
+		failed = append(failed, bad...)
+		if len(ok) > 0 && updated != content {
+			files[path] = updated
+			applied = append(applied, ok...)
+		}
+	}
+
+	if len(files) == 0 {
+		return report(formatAutofixReport(nil, skipped, failed, ""))
+	}
+
+	// Failures are retried; the last attempt tells the requester instead
+	fail := func(err error) error {
+		retried, ok := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		if ok && retried < maxRetry {
+			return err
+		}
+		log.Printf(" Autofix for PR #%d failed: %v", payload.PRNumber, err)
+		return report(fmt.Sprintf("I couldn't push the fixes: %v", err))
+	}
+
+	// The branch and its PR are reused by retries and repeated requests
+	branch := fmt.Sprintf("ai-autofix/pr-%d-%.7s", payload.PRNumber, headSHA)
+	message := fmt.Sprintf("Apply AI review suggestions for #%d", payload.PRNumber)
+	if _, err := ghService.CommitFiles(ctx, payload.RepoOwner, payload.RepoName, headSHA, branch, message, files); err != nil {
+		return fail(err)
+	}
+
+	followUp, err := ghService.FindOpenPullRequest(ctx, payload.RepoOwner, payload.RepoName, payload.RepoOwner+":"+branch, pr.GetHead().GetRef())
+	if err != nil {
+		return fail(err)
+	}
+	if followUp == nil {
+		body := fmt.Sprintf("Applies %d suggestion(s) from the AI review of #%d.\n\n%s", len(applied), payload.PRNumber, formatFixList(applied))
+		if payload.RequestedBy != "" {
+			body += fmt.Sprintf("\nRequested by @%s.", payload.RequestedBy)
+		}
+		if followUp, err = ghService.OpenPullRequest(ctx, payload.RepoOwner, payload.RepoName, message, branch, pr.GetHead().GetRef(), body); err != nil {
+			return fail(err)
+		}
+	}
+
+	log.Printf("Autofix PR #%d opened for PR #%d", followUp.GetNumber(), payload.PRNumber)
+	return report(formatAutofixReport(applied, skipped, failed, followUp.GetHTMLURL()))
+}
+
+func formatAutofixReport(applied, skipped, failed []service.Fix, prURL string) string {
+	var sb strings.Builder
+	if prURL != "" {
+		fmt.Fprintf(&sb, "Opened %s with %d fix(es):\n\n%s\n", prURL, len(applied), formatFixList(applied))
+	} else {
+		sb.WriteString("No fixes could be applied.\n\n")
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(&sb, "**Left out, overlapping edits** (apply these by hand):\n\n%s\n", formatFixList(skipped))
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(&sb, "**Left out, range no longer matches the file:**\n\n%s\n", formatFixList(failed))
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func formatFixList(fixes []service.Fix) string {
+	var sb strings.Builder
+	for _, fix := range fixes {
+		fmt.Fprintf(&sb, "- `%s`\n", fix)
+	}
+	return sb.String()
+}
+
+// queueForcedReview reviews the PR head again. The task ID is the one the
+// ai-review label uses, so both collapse into one review.
+func queueForcedReview(payload AutofixPayload, pr *github.PullRequest) error {
+	if Client == nil {
+		return fmt.Errorf("no job queue client")
+	}
+	task, err := NewReviewTask(ReviewPayload{
+		RepoName:       payload.RepoName,
+		RepoOwner:      payload.RepoOwner,
+		PRNumber:       payload.PRNumber,
+		RepoID:         pr.GetBase().GetRepo().GetID(),
+		HeadSHA:        pr.GetHead().GetSHA(),
+		BaseSHA:        pr.GetBase().GetSHA(),
+		Draft:          pr.GetDraft(),
+		Force:          true,
+		InstallationID: payload.InstallationID,
+	})
+	if err != nil {
+		return err
+	}
+	taskID := fmt.Sprintf("review:%s/%s:%d:%s:forced", payload.RepoOwner, payload.RepoName, payload.PRNumber, pr.GetHead().GetSHA())
+	_, err = Client.Enqueue(task, asynq.TaskID(taskID), asynq.Retention(1*time.Hour))
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+)
+
+// maxListedTransitive keeps a lockfile regeneration from flooding the review
+const maxListedTransitive = 20
+
+// formatDependencySection lists direct dependency changes and anything
+// flagged, then the new transitive dependencies by name
+func formatDependencySection(changes []service.DependencyChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var rows []service.DependencyChange
+	var transitive []string
+	for _, c := range changes {
+		if c.Direct || c.Flagged() {
+			rows = append(rows, c)
+		}
+		if !c.Direct && c.Kind() == "added" {
+			transitive = append(transitive, fmt.Sprintf("`%s@%s`", c.Name, c.To))
+		}
+	}
+	if len(rows) == 0 && len(transitive) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n### 📦 Dependency Changes\n\n")
+	if len(rows) > 0 {
+		sb.WriteString("| Package | Change | Notes |\n")
+		sb.WriteString("| :--- | :--- | :--- |\n")
+		for _, c := range rows {
+			fmt.Fprintf(&sb, "| `%s` (%s) | %s | %s |\n", c.Name, c.Ecosystem, dependencyChangeText(c), tableCell(dependencyNotes(c)))
+		}
+	}
+
+	if len(transitive) > 0 {
+		fmt.Fprintf(&sb, "\n**New transitive dependencies (%d):** ", len(transitive))
+		if len(transitive) > maxListedTransitive {
+			sb.WriteString(strings.Join(transitive[:maxListedTransitive], ", "))
+			fmt.Fprintf(&sb, " and %d more", len(transitive)-maxListedTransitive)
+		} else {
+			sb.WriteString(strings.Join(transitive, ", "))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func dependencyChangeText(c service.DependencyChange) string {
+	switch c.Kind() {
+	case "added":
+		return "added " + c.To
+	case "removed":
+		return "removed " + c.From
+	}
+	return fmt.Sprintf("%s %s → %s", c.Kind(), c.From, c.To)
+}
+
+func dependencyNotes(c service.DependencyChange) string {
+	var notes []string
+	for _, a := range c.Advisories {
+		note := fmt.Sprintf("%s **%s** (%s)", severityIcon(a.Severity), a.ID, a.Severity)
+		if a.Summary != "" {
+			note += " " + a.Summary
+		}
+		if a.Fixed != "" {
+			note += ", fixed in " + a.Fixed
+		}
+		notes = append(notes, note)
+	}
+	if c.MajorJump {
+		notes = append(notes, "⚠️ major version jump, check the changelog for breaking changes")
+	}
+	if c.DisallowedLicense {
+		notes = append(notes, fmt.Sprintf("🚫 license `%s` is not on the allowlist", c.License))
+	}
+	if !c.Direct {
+		notes = append(notes, "transitive")
+	}
+	return strings.Join(notes, "; ")
+}
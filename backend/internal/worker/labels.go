@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+)
+
+// Default label names and thresholds, used when a repository leaves them empty
+var defaultLabels = model.LabelSettings{
+	MediumThreshold: 30,
+	HighThreshold:   60,
+	RiskLow:         "risk:low",
+	RiskMedium:      "risk:medium",
+	RiskHigh:        "risk:high",
+	Security:        "ai:security",
+	LGTM:            "ai:lgtm",
+}
+
+// resolveLabels fills the unset label settings with the defaults
+func resolveLabels(s model.LabelSettings) model.LabelSettings {
+	pick := func(name, fallback string) string {
+		if name == "" {
+			return fallback
+		}
+		return name
+	}
+	s.RiskLow = pick(s.RiskLow, defaultLabels.RiskLow)
+	s.RiskMedium = pick(s.RiskMedium, defaultLabels.RiskMedium)
+	s.RiskHigh = pick(s.RiskHigh, defaultLabels.RiskHigh)
+	s.Security = pick(s.Security, defaultLabels.Security)
+	s.LGTM = pick(s.LGTM, defaultLabels.LGTM)
+	if s.MediumThreshold <= 0 {
+		s.MediumThreshold = defaultLabels.MediumThreshold
+	}
+	if s.HighThreshold <= 0 {
+		s.HighThreshold = defaultLabels.HighThreshold
+	}
+	return s
+}
+
+// riskLevel maps a score to "low", "medium" or "high"
+func riskLevel(score int, s model.LabelSettings) string {
+	switch {
+	case score >= s.HighThreshold:
+		return "high"
+	case score >= s.MediumThreshold:
+		return "medium"
+	}
+	return "low"
+}
+
+// desiredLabels picks the labels for a review. managed holds every label this
+// bot owns, both the configured and the default names, so labels from an
+// earlier review (or an earlier configuration) are cleaned up.
+func desiredLabels(s model.LabelSettings, level string, issues []service.ReviewIssue) (desired, managed []string) {
+	hasSecurity, lgtm := false, true
+	for _, issue := range issues {
+		if strings.EqualFold(issue.Type, "security") {
+			hasSecurity = true
+		}
+		switch strings.ToLower(issue.Severity) {
+		case "high", "critical", "medium":
+			lgtm = false
+		}
+	}
+
+	want := map[string]bool{
+		s.RiskLow:    level == "low",
+		s.RiskMedium: level == "medium",
+		s.RiskHigh:   level == "high",
+		s.Security:   hasSecurity,
+		s.LGTM:       lgtm,
+	}
+
+	for _, name := range []string{s.RiskLow, s.RiskMedium, s.RiskHigh, s.Security, s.LGTM,
+		defaultLabels.RiskLow, defaultLabels.RiskMedium, defaultLabels.RiskHigh, defaultLabels.Security, defaultLabels.LGTM} {
+		if name == "-" || contains(managed, name) {
+			continue
+		}
+		managed = append(managed, name)
+		if want[name] {
+			desired = append(desired, name)
+		}
+	}
+	return desired, managed
+}
+
+// applyRiskLabels scores the PR, syncs its labels when it is on GitHub and
+// returns the risk section for the review comment. Label failures are only
+// logged.
+func applyRiskLabels(ctx context.Context, ghService *service.GitHubService, payload ReviewPayload, settings model.LabelSettings, input service.RiskInput) string {
+	settings = resolveLabels(settings)
+	risk := service.AssessRisk(input)
+	level := riskLevel(risk.Score, settings)
+
+	if !settings.Disabled && ghService != nil {
+		desired, managed := desiredLabels(settings, level, input.Issues)
+		if err := ghService.SyncLabels(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber, desired, managed); err != nil {
+			log.Printf(" Failed to apply labels to PR #%d: %v", payload.PRNumber, err)
+		}
+	}
+	return formatRiskSection(risk, level)
+}
+
+func formatRiskSection(risk service.RiskAssessment, level string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n\n### 📊 Risk: %s %s (%d/100)\n\n", severityIcon(level), level, risk.Score)
+	for _, factor := range risk.Factors {
+		fmt.Fprintf(&sb, "- %s\n", factor)
+	}
+	return sb.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/database"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+)
+
+// prTaskTypes are the tasks that only make sense while a PR is open
+var prTaskTypes = map[string]bool{
+	TypeReviewPR:     true,
+	TypeReplyComment: true,
+	TypeAutofixPR:    true,
+}
+
+// prRef is the part every PR task payload has in common
+type prRef struct {
+	RepoName  string `json:"repo_name"`
+	RepoOwner string `json:"repo_owner"`
+	PRNumber  int    `json:"pr_number"`
+	Provider  string `json:"provider,omitempty"` // GitHub when empty
+}
+
+// CancelPRTasks deletes the waiting tasks of a closed PR and asks running
+// ones to stop. It returns how many tasks were cancelled. The provider
+// keeps a GitLab MR from cancelling the GitHub PR with the same number.
+func CancelPRTasks(inspector *asynq.Inspector, provider, owner, name string, prNumber int) (int, error) {
+	queues, err := inspector.Queues()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list queues: %w", err)
+	}
+
+	matches := func(t *asynq.TaskInfo) bool {
+		var ref prRef
+		if !prTaskTypes[t.Type] || json.Unmarshal(t.Payload, &ref) != nil {
+			return false
+		}
+		return providerOrGitHub(ref.Provider) == providerOrGitHub(provider) &&
+			ref.RepoOwner == owner && ref.RepoName == name && ref.PRNumber == prNumber
+	}
+
+	cancelled := 0
+	for _, queue := range queues {
+		listers := []taskLister{inspector.ListPendingTasks, inspector.ListScheduledTasks, inspector.ListRetryTasks}
+		for _, list := range listers {
+			tasks, err := matchingTasks(list, queue, matches)
+			if err != nil {
+				return cancelled, fmt.Errorf("failed to list tasks in %s: %w", queue, err)
+			}
+			for _, t := range tasks {
+				if inspector.DeleteTask(queue, t.ID) == nil {
+					cancelled++
+				}
+			}
+		}
+
+		active, err := matchingTasks(inspector.ListActiveTasks, queue, matches)
+		if err != nil {
+			return cancelled, fmt.Errorf("failed to list active tasks in %s: %w", queue, err)
+		}
+		for _, t := range active {
+			if inspector.CancelProcessing(t.ID) == nil {
+				cancelled++
+			}
+		}
+	}
+	return cancelled, nil
+}
+
+func providerOrGitHub(provider string) string {
+	if provider == "" {
+		return service.ProviderGitHub
+	}
+	return provider
+}
+
+// taskPageSize is how many tasks are listed from a queue at a time
+const taskPageSize = 1000
+
+type taskLister func(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+
+// matchingTasks walks every page of a task list and keeps the matching tasks.
+// They are only acted on afterwards, since deleting tasks shifts the pages.
+func matchingTasks(list taskLister, queue string, matches func(*asynq.TaskInfo) bool) ([]*asynq.TaskInfo, error) {
+	var found []*asynq.TaskInfo
+	for page := 1; ; page++ {
+		tasks, err := list(queue, asynq.PageSize(taskPageSize), asynq.Page(page))
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tasks {
+			if matches(t) {
+				found = append(found, t)
+			}
+		}
+		if len(tasks) < taskPageSize {
+			return found, nil
+		}
+	}
+}
+
+// HandleMergedTask records the final state of a merged PR
+func HandleMergedTask(ctx context.Context, t *asynq.Task) error {
+	var payload MergedPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	repo, err := repository.NewRepoRepository(database.Pool).GetRepositoryByOwnerName(ctx, payload.RepoOwner, payload.RepoName)
+	if err != nil {
+		return err
+	}
+	if repo == nil {
+		log.Printf(" Repository %s/%s is not registered, merge of PR #%d not recorded", payload.RepoOwner, payload.RepoName, payload.PRNumber)
+		return nil
+	}
+
+	outcome := &model.PROutcome{
+		RepositoryID:   repo.ID,
+		PRNumber:       payload.PRNumber,
+		MergedAt:       payload.MergedAt,
+		MergeCommitSHA: payload.MergeCommitSHA,
+		HeadSHA:        payload.HeadSHA,
+		Additions:      payload.Additions,
+		Deletions:      payload.Deletions,
+		ChangedFiles:   payload.ChangedFiles,
+	}
+	if err := repository.NewOutcomeRepository(database.Pool).RecordMergedPR(ctx, outcome); err != nil {
+		return err
+	}
+
+	log.Printf("Recorded merge of PR #%d (%d finding(s), %d false positive(s))", payload.PRNumber, outcome.Findings, outcome.FalsePositives)
+	return nil
+}
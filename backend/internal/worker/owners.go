@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+)
+
+// loadCodeOwners reads CODEOWNERS at the base SHA, so a PR can't route its
+// own review by editing the file. Without one, the configured rules apply.
+func loadCodeOwners(ctx context.Context, ghService *service.GitHubService, payload ReviewPayload, cfg *model.Configuration) *service.CodeOwners {
+	if payload.BaseSHA != "" {
+		for _, path := range service.CodeOwnersPaths {
+			content, err := ghService.GetFileContent(ctx, payload.RepoOwner, payload.RepoName, path, payload.BaseSHA)
+			if err == nil {
+				return service.ParseCodeOwners(content)
+			}
+		}
+	}
+	if strings.TrimSpace(cfg.OwnershipRules) != "" {
+		return service.ParseCodeOwners(cfg.OwnershipRules)
+	}
+	return nil
+}
+
+// routeToOwners requests reviews from the owners of the touched paths and
+// mentions them on high-severity findings in their code. Failures are logged
+// and never fail the review.
+func routeToOwners(ctx context.Context, ghService *service.GitHubService, payload ReviewPayload, cfg *model.Configuration, diff string, issues []service.ReviewIssue) {
+	owners := loadCodeOwners(ctx, ghService, payload, cfg)
+	if owners == nil {
+		return
+	}
+
+	for i, issue := range issues {
+		if strings.EqualFold(issue.Severity, "high") || strings.EqualFold(issue.Severity, "critical") {
+			issues[i].Mentions = owners.OwnersOf(issue.File)
+		}
+	}
+
+	var paths []string
+	for _, f := range service.NewDiffParser().ParseAll(diff) {
+		paths = append(paths, f.Path)
+	}
+
+	author := ""
+	if pr, err := ghService.GetPullRequest(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber); err == nil {
+		author = pr.GetUser().GetLogin()
+	}
+	users, teams := owners.ReviewersFor(paths, author)
+	if err := ghService.RequestReviewers(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber, users, teams); err != nil {
+		log.Printf(" Failed to request code owner reviews for PR #%d: %v", payload.PRNumber, err)
+		return
+	}
+	if len(users)+len(teams) > 0 {
+		log.Printf("Requested reviews from %d user(s) and %d team(s) on PR #%d", len(users), len(teams), payload.PRNumber)
+	}
+}
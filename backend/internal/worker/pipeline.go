@@ -0,0 +1,167 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+)
+
+// reviewTarget is what a review covers: a PR, or commits pushed to a branch
+type reviewTarget struct {
+	Owner   string
+	Name    string
+	HeadSHA string // Files are read at this commit; empty skips the context and analyzers
+	Label   string // e.g. "PR #12" or "push to main", for the logs
+	// PR is nil for pushes, which skip the PR-only steps: the description
+	// summary, code owner mentions and risk labels
+	PR *ReviewPayload
+}
+
+// reviewOutcome is a finished review, ready to be posted
+type reviewOutcome struct {
+	Files  []service.FileChange
+	Issues []service.ReviewIssue
+	Body   string // Findings table followed by the dependency and skipped-file sections
+}
+
+// runReviewPipeline reviews a diff: generated files are dropped, secrets are
+// scanned for, the data policy and redaction are applied to what the AI sees,
+// and its findings are merged with those of the local analyzers.
+func runReviewPipeline(ctx context.Context, scm service.SCMProvider, target reviewTarget, cfg *model.Configuration, diff string) (*reviewOutcome, error) {
+	// Labels, reviewer requests and directory listings are GitHub only
+	ghService, _ := scm.(*service.GitHubService)
+
+	fetch := func(path string) (string, error) {
+		return scm.GetFileContent(ctx, target.Owner, target.Name, path, target.HeadSHA)
+	}
+
+	// Generated files are left out of everything the AI and the analyzers see
+	parser := service.NewDiffParser()
+	if target.HeadSHA != "" {
+		gitattributes, _ := fetch(".gitattributes")
+		parser.WithGenerated(cfg.GeneratedPatterns, gitattributes, fetch)
+	} else {
+		parser.WithGenerated(cfg.GeneratedPatterns, "", nil)
+	}
+	files := parser.Parse(diff)
+	reviewDiff := parser.StripSkipped(diff)
+	if len(parser.Skipped) > 0 {
+		log.Printf(" Skipping %d generated file(s) in %s", len(parser.Skipped), target.Label)
+	}
+
+	// Secrets are looked for before anything is sent to the AI
+	scanner := newSecretScanner(cfg)
+	secretIssues := scanForSecrets(ctx, scm, target, cfg, scanner, diff)
+	if len(secretIssues) > 0 && cfg.SecretScanBlocking {
+		log.Printf(" Secret scan gate failed for %s, skipping AI review", target.Label)
+		return &reviewOutcome{Files: files, Issues: secretIssues, Body: formatReviewToMarkdown(secretIssues)}, nil
+	}
+
+	aiService := service.NewAIService()
+	defer aiService.Close()
+
+	// Everything below goes to a third party, so it only ever sees placeholders
+	// and only the files the data policy lets out of the network
+	redactor := newRedactor(cfg, scanner)
+	policy, err := loadDataPolicy(ctx, target.Owner, target.Name)
+	if err != nil {
+		log.Printf(" %v", err)
+		return nil, err
+	}
+	aiDiff, withheld := policy.FilterDiff(reviewDiff, aiService)
+	aiFiles, _ := policy.Filter(files, aiService)
+	if len(withheld) > 0 {
+		log.Printf(" Data policy withheld %d file(s) of %s from %s", len(withheld), target.Label, aiService.ProviderName())
+	}
+
+	if target.PR != nil {
+		postPRSummary(ctx, scm, aiService, *target.PR, cfg.SummaryMode, aiFiles, redactor)
+	}
+
+	// Oversized diffs get their riskiest files reviewed rather than the first ones
+	aiDiff, omitted := service.PrioritizeDiff(aiDiff, cfg.HotspotPatterns, service.MaxReviewDiffSize)
+	aiFiles = service.ExcludeFiles(aiFiles, omitted)
+	if len(omitted) > 0 {
+		log.Printf(" %s is over the review budget, leaving out %d file(s)", target.Label, len(omitted))
+	}
+
+	var fileContext string
+	var staticIssues []service.ReviewIssue
+	if target.HeadSHA != "" {
+		list := func(dir string) ([]string, error) {
+			if ghService == nil {
+				return nil, fmt.Errorf("listing directories is not supported on this code host")
+			}
+			return ghService.ListDirectory(ctx, target.Owner, target.Name, dir, target.HeadSHA)
+		}
+		aiFetch := policy.Fetcher(fetch, aiService)
+		var wholeFiles map[string]bool
+		fileContext, wholeFiles = service.BuildFileContext(aiFiles, aiFetch, service.MaxContextSize)
+		fileContext += service.BuildGoContext(aiFiles, aiFetch, list, wholeFiles, service.MaxGoContextSize)
+		// The analyzers run locally, so they still cover withheld files
+		staticIssues = service.RunGoChecks(files, fetch)
+	}
+
+	var promptFindings []service.ReviewIssue
+	for _, issue := range staticIssues {
+		if policy.Check(issue.File, aiService) == "" {
+			promptFindings = append(promptFindings, issue)
+		}
+	}
+
+	// Text in the diff that tries to steer the reviewer is reported, never obeyed
+	injectionIssues := service.DetectInjection(files)
+
+	reviewJSON := "[]"
+	var aiIssues []service.ReviewIssue
+	var parseErr error
+	if strings.TrimSpace(aiDiff) != "" {
+		input := service.ReviewInput{
+			Diff:           redactor.Redact(aiDiff),
+			Context:        redactor.Redact(fileContext),
+			Style:          "concise",
+			StaticFindings: promptFindings,
+		}
+		suspicious := len(injectionIssues) > 0 && len(aiDiff) >= service.SuspiciousDiffSize
+		reviewJSON, aiIssues, parseErr, err = reviewWithAI(ctx, aiService, input, suspicious)
+		if err != nil {
+			log.Printf("❌ AI Analysis failed: %v", err)
+			return nil, err
+		}
+		log.Printf("Sent redacted code to the AI for %s (%s)", target.Label, redactor)
+	}
+
+	aiIssues = redactor.RestoreIssues(aiIssues)
+	localIssues := append(append(secretIssues, injectionIssues...), staticIssues...)
+	issues := append(localIssues, aiIssues...)
+	if target.PR != nil && ghService != nil {
+		routeToOwners(ctx, ghService, *target.PR, cfg, diff, issues)
+	}
+	body := fmt.Sprintf("## 🤖 AI Review\n\n%s", redactor.Restore(reviewJSON))
+	if parseErr == nil || len(localIssues) > 0 {
+		body = formatReviewToMarkdown(issues)
+	}
+	// Manifests and lockfiles are never sent to the AI, so they are checked here
+	advisories, err := service.LoadAdvisories()
+	if err != nil {
+		log.Printf(" Skipping advisory checks: %v", err)
+	}
+	dependencies := service.AnalyzeDependencies(diff, advisories, cfg.LicenseAllowlist)
+	if target.PR != nil {
+		body += applyRiskLabels(ctx, ghService, *target.PR, cfg.Labels, service.RiskInput{
+			Files:        files,
+			Issues:       issues,
+			Dependencies: dependencies,
+		})
+	}
+	body += formatDependencySection(dependencies)
+	body += formatWithheldFiles(withheld, aiService.ProviderName())
+	body += formatSkippedFiles(parser.Skipped)
+	body += formatOmittedFiles(omitted)
+
+	return &reviewOutcome{Files: files, Issues: issues, Body: body}, nil
+}
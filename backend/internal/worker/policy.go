@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/database"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+)
+
+// loadDataPolicy merges the organization policy with the repository policy.
+// Unlike the review config it fails closed: if the policy can't be read, no
+// code is sent anywhere and the task is retried.
+func loadDataPolicy(ctx context.Context, owner, name string) (*service.DataPolicy, error) {
+	repoID := 0
+	repo, err := repository.NewRepoRepository(database.Pool).GetRepositoryByOwnerName(ctx, owner, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve repository for data policy: %w", err)
+	}
+	if repo != nil {
+		repoID = repo.ID
+	}
+
+	stored, err := repository.NewPolicyRepository(database.Pool).ListEffectivePolicies(ctx, owner, repoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data policy: %w", err)
+	}
+
+	policy := &service.DataPolicy{}
+	for _, p := range stored {
+		parsed, err := service.NewDataPolicy(p.NeverSend, p.LocalOnly)
+		if err != nil {
+			return nil, fmt.Errorf("data policy %d is invalid: %w", p.ID, err)
+		}
+		policy.Merge(parsed)
+	}
+	return policy, nil
+}
+
+func formatWithheldFiles(withheld []service.WithheldFile, provider string) string {
+	if len(withheld) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n\n### 🔒 Not sent to %s\n\n", provider)
+	sb.WriteString("These files were withheld by the data policy and were only checked by the built-in scanners.\n\n")
+	sb.WriteString("| File | Reason |\n")
+	sb.WriteString("| :--- | :--- |\n")
+	for _, w := range withheld {
+		fmt.Fprintf(&sb, "| `%s` | %s |\n", w.Path, tableCell(w.Reason))
+	}
+	return sb.String()
+}
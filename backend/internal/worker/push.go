@@ -0,0 +1,239 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/hibiken/asynq"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/database"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+)
+
+// pushCheckName is the check run a push review reports on its head commit
+const pushCheckName = "AI Code Review (push)"
+
+// maxCheckSummary is GitHub's limit on a check run summary
+const maxCheckSummary = 65535
+
+// HandlePushReviewTask reviews commits pushed straight to a configured
+// branch. Findings go to a commit comment and a check run on the head
+// commit, since there is no PR to comment on.
+func HandlePushReviewTask(ctx context.Context, t *asynq.Task) error {
+	var payload PushPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	commitRange := shortSHA(payload.BeforeSHA) + ".." + shortSHA(payload.HeadSHA)
+	log.Printf("Processing Push Review for: %s/%s@%s %s", payload.RepoOwner, payload.RepoName, payload.Branch, commitRange)
+
+	if installationSuspended(ctx, payload.InstallationID) {
+		return nil
+	}
+
+	ghService, err := service.NewGitHubServiceFor(ctx, payload.RepoOwner, payload.RepoName, payload.InstallationID)
+	if err != nil {
+		return err
+	}
+
+	// Merging a PR pushes too, and the PR has been reviewed already
+	if merged, err := ghService.InMergedPullRequest(ctx, payload.RepoOwner, payload.RepoName, payload.HeadSHA); err != nil {
+		log.Printf(" %v", err)
+	} else if merged {
+		log.Printf(" Skipping push %s: it came from a merged PR", commitRange)
+		return nil
+	}
+
+	checkRunID, err := ghService.StartCheckRun(ctx, payload.RepoOwner, payload.RepoName, payload.HeadSHA, pushCheckName)
+	if err != nil {
+		// Check runs need a GitHub App; with a token the result goes to a commit status
+		log.Printf(" %v, reporting a commit status instead", err)
+	}
+	// A check left in progress would hang on the commit forever
+	fail := func(err error) error {
+		failPushCheck(ctx, ghService, payload, checkRunID, err)
+		return err
+	}
+
+	diff, err := ghService.GetCompareDiff(ctx, payload.RepoOwner, payload.RepoName, payload.BeforeSHA, payload.HeadSHA)
+	if err != nil {
+		log.Printf(" Failed to get diff: %v", err)
+		return fail(err)
+	}
+	if strings.TrimSpace(diff) == "" {
+		log.Println(" Diff is empty, skipping review.")
+		return completePushCheck(ctx, ghService, payload, checkRunID, nil, nil, "Nothing to review in "+commitRange)
+	}
+
+	target := reviewTarget{
+		Owner:   payload.RepoOwner,
+		Name:    payload.RepoName,
+		HeadSHA: payload.HeadSHA,
+		Label:   "push to " + payload.Branch,
+	}
+	outcome, err := runReviewPipeline(ctx, ghService, target, loadRepoConfig(ctx, payload.RepoOwner, payload.RepoName), diff)
+	if err != nil {
+		return fail(err)
+	}
+
+	header := fmt.Sprintf("Commits `%s` pushed to `%s`", commitRange, payload.Branch)
+	if payload.Pusher != "" {
+		header += " by @" + payload.Pusher
+	}
+	commentBody := header + "\n\n" + outcome.Body + "\n" + service.BotCommentMarker
+
+	if err := ghService.PostCommitComment(ctx, payload.RepoOwner, payload.RepoName, payload.HeadSHA, commentBody); err != nil {
+		log.Printf(" %v", err)
+		return fail(err)
+	}
+	log.Printf("Review Posted for push %s to %s!", commitRange, payload.Branch)
+
+	storePushReview(ctx, payload, outcome.Issues)
+	return completePushCheck(ctx, ghService, payload, checkRunID, outcome.Files, outcome.Issues, commentBody)
+}
+
+// failPushCheck reports a review that could not finish. The check is
+// neutral rather than failed, since nothing is known about the code, and
+// the next attempt picks the same check run up again.
+func failPushCheck(ctx context.Context, ghService *service.GitHubService, payload PushPayload, checkRunID int64, reviewErr error) {
+	title := "The review could not be completed"
+	summary := fmt.Sprintf("The AI review of these commits failed: %v", reviewErr)
+	retried, _ := asynq.GetRetryCount(ctx)
+	if maxRetry, ok := asynq.GetMaxRetry(ctx); ok && retried < maxRetry {
+		summary += "\n\nIt will be retried."
+	}
+
+	if checkRunID == 0 {
+		if err := ghService.SetCommitStatus(ctx, payload.RepoOwner, payload.RepoName, payload.HeadSHA, "error", "ai-code-review/push", title); err != nil {
+			log.Printf(" Failed to report push review status: %v", err)
+		}
+		return
+	}
+	if err := ghService.CompleteCheckRun(ctx, payload.RepoOwner, payload.RepoName, checkRunID, pushCheckName, "neutral", title, summary, nil); err != nil {
+		log.Printf(" %v", err)
+	}
+}
+
+// completePushCheck concludes the check run with one annotation per finding
+// on a changed line, or sets a commit status when there is no check run.
+// High severity findings fail the check; others make it neutral.
+func completePushCheck(ctx context.Context, ghService *service.GitHubService, payload PushPayload, checkRunID int64, files []service.FileChange, issues []service.ReviewIssue, summary string) error {
+	conclusion, title := "success", "No issues found"
+	high := 0
+	for _, issue := range issues {
+		if s := strings.ToLower(issue.Severity); s == "high" || s == "critical" {
+			high++
+		}
+	}
+	switch {
+	case high > 0:
+		conclusion, title = "failure", fmt.Sprintf("%d finding(s), %d of high severity", len(issues), high)
+	case len(issues) > 0:
+		conclusion, title = "neutral", fmt.Sprintf("%d finding(s)", len(issues))
+	}
+
+	if checkRunID == 0 {
+		state := map[string]string{"success": "success", "neutral": "success", "failure": "failure"}[conclusion]
+		if err := ghService.SetCommitStatus(ctx, payload.RepoOwner, payload.RepoName, payload.HeadSHA, state, "ai-code-review/push", title); err != nil {
+			log.Printf(" Failed to report push review status: %v", err)
+		}
+		return nil
+	}
+
+	changed := make(map[string]service.FileChange)
+	for _, f := range files {
+		changed[f.Path] = f
+	}
+	var annotations []*github.CheckRunAnnotation
+	for _, issue := range issues {
+		file, ok := changed[issue.File]
+		if !ok || !file.HasLine(issue.Line) {
+			continue
+		}
+		message := issue.Message
+		if issue.Suggestion != "" {
+			message += "\n\n" + issue.Suggestion
+		}
+		annotations = append(annotations, &github.CheckRunAnnotation{
+			Path:            github.String(issue.File),
+			StartLine:       github.Int(issue.Line),
+			EndLine:         github.Int(issue.Line),
+			AnnotationLevel: github.String(annotationLevel(issue.Severity)),
+			Title:           github.String(issue.Type),
+			Message:         github.String(message),
+		})
+	}
+
+	if len(summary) > maxCheckSummary {
+		summary = summary[:maxCheckSummary-3] + "..."
+	}
+	if err := ghService.CompleteCheckRun(ctx, payload.RepoOwner, payload.RepoName, checkRunID, pushCheckName, conclusion, title, summary, annotations); err != nil {
+		log.Printf(" %v", err)
+	}
+	return nil
+}
+
+func annotationLevel(severity string) string {
+	switch strings.ToLower(severity) {
+	case "high", "critical":
+		return "failure"
+	case "medium":
+		return "warning"
+	}
+	return "notice"
+}
+
+// storePushReview records the review against its commit range
+func storePushReview(ctx context.Context, payload PushPayload, issues []service.ReviewIssue) {
+	repo, err := repository.NewRepoRepository(database.Pool).GetRepositoryByOwnerName(ctx, payload.RepoOwner, payload.RepoName)
+	if err != nil || repo == nil {
+		log.Printf(" Repository %s/%s is not registered, findings will not be stored", payload.RepoOwner, payload.RepoName)
+		return
+	}
+
+	reviewRepo := repository.NewReviewRepository(database.Pool)
+	reviewID, err := reviewRepo.CreateCommitRangeReview(ctx, repo.ID, payload.BeforeSHA+".."+payload.HeadSHA, payload.HeadSHA)
+	if err != nil {
+		log.Printf(" Failed to store review: %v", err)
+		return
+	}
+
+	issueRepo := repository.NewIssueRepository(database.Pool)
+	for _, issue := range issues {
+		record := &model.ReviewIssue{
+			ReviewID:      reviewID,
+			FilePath:      issue.File,
+			LineNumber:    issue.Line,
+			Severity:      issue.Severity,
+			Category:      issue.Type,
+			Message:       issue.Message,
+			Suggestion:    issue.Suggestion,
+			StartLine:     issue.StartLine,
+			EndLine:       issue.EndLine,
+			SuggestedCode: issue.SuggestedCode,
+			Source:        issue.Source,
+		}
+		if err := issueRepo.CreateIssue(ctx, record); err != nil {
+			log.Printf(" Failed to store finding: %v", err)
+		}
+	}
+
+	content, _ := json.Marshal(issues)
+	if err := reviewRepo.UpdateReviewResult(ctx, reviewID, string(content)); err != nil {
+		log.Printf(" Failed to complete review record: %v", err)
+	}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
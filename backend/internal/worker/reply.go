@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/database"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+)
+
+// HandleReplyTask answers a developer who replied under one of our inline
+// findings, using the finding, its diff hunk and the thread so far.
+func HandleReplyTask(ctx context.Context, t *asynq.Task) error {
+	var payload ReplyPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	if installationSuspended(ctx, payload.InstallationID) {
+		return nil
+	}
+
+	issueRepo := repository.NewIssueRepository(database.Pool)
+	issue, err := issueRepo.GetByCommentID(ctx, payload.InReplyTo)
+	if err != nil {
+		return err
+	}
+	if issue == nil {
+		log.Printf(" Skipping reply %d: thread %d is not one of our findings", payload.CommentID, payload.InReplyTo)
+		return nil
+	}
+
+	if err := issueRepo.AddThreadMessage(ctx, &model.ThreadMessage{
+		IssueID:         issue.ID,
+		GithubCommentID: payload.CommentID,
+		Author:          payload.Author,
+		Body:            payload.Body,
+	}); err != nil {
+		return err
+	}
+
+	history, err := issueRepo.ListThreadMessages(ctx, issue.ID)
+	if err != nil {
+		return err
+	}
+
+	ghService, err := service.NewGitHubServiceFor(ctx, payload.RepoOwner, payload.RepoName, payload.InstallationID)
+	if err != nil {
+		return err
+	}
+	root, err := ghService.GetReviewComment(ctx, payload.RepoOwner, payload.RepoName, issue.GithubCommentID)
+	if err != nil {
+		return err
+	}
+
+	aiService := service.NewAIService()
+	defer aiService.Close()
+
+	policy, err := loadDataPolicy(ctx, payload.RepoOwner, payload.RepoName)
+	if err != nil {
+		return err
+	}
+	if reason := policy.Check(issue.FilePath, aiService); reason != "" {
+		log.Printf(" Not sending thread %d to %s: %s", issue.GithubCommentID, aiService.ProviderName(), reason)
+		body := fmt.Sprintf("🔒 `%s` is covered by this repository's data policy (%s), so I can't discuss it with %s. A human reviewer will need to take a look.\n\n%s",
+			issue.FilePath, reason, aiService.ProviderName(), service.BotCommentMarker)
+		_, err := ghService.ReplyToReviewComment(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber, issue.GithubCommentID, body)
+		return err
+	}
+
+	// The thread quotes code and may quote secrets, so it is redacted like a review
+	repoConfig := loadRepoConfig(ctx, payload.RepoOwner, payload.RepoName)
+	redactor := newRedactor(repoConfig, newSecretScanner(repoConfig))
+
+	var thread []service.ThreadMessage
+	for _, msg := range history {
+		thread = append(thread, service.ThreadMessage{Author: msg.Author, Body: redactor.Redact(msg.Body), FromBot: msg.FromBot})
+	}
+
+	reply, err := aiService.ReplyToThread(ctx, service.ReviewIssue{
+		File:       issue.FilePath,
+		Line:       issue.LineNumber,
+		Type:       issue.Category,
+		Severity:   issue.Severity,
+		Message:    redactor.Redact(issue.Message),
+		Suggestion: redactor.Redact(issue.Suggestion),
+	}, redactor.Redact(root.GetDiffHunk()), thread)
+	if err != nil {
+		log.Printf("❌ AI reply failed: %v", err)
+		return err
+	}
+	reply.Reply = redactor.Restore(reply.Reply)
+
+	body := reply.Reply
+	if reply.FalsePositive && !issue.FalsePositive {
+		if err := issueRepo.MarkFalsePositive(ctx, issue.ID); err != nil {
+			log.Printf(" Failed to mark finding %d as false positive: %v", issue.ID, err)
+		} else {
+			body += "\n\n_✅ Marked this finding as a false positive._"
+		}
+	}
+	body += "\n\n" + service.BotCommentMarker
+
+	replyID, err := ghService.ReplyToReviewComment(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber, issue.GithubCommentID, body)
+	if err != nil {
+		return err
+	}
+
+	if err := issueRepo.AddThreadMessage(ctx, &model.ThreadMessage{
+		IssueID:         issue.ID,
+		GithubCommentID: replyID,
+		Author:          "ai-code-review",
+		Body:            reply.Reply,
+		FromBot:         true,
+	}); err != nil {
+		log.Printf(" Failed to record bot reply: %v", err)
+	}
+
+	log.Printf("Replied in thread %d on PR #%d", issue.GithubCommentID, payload.PRNumber)
+	return nil
+}
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/database"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+)
+
+// newSCMProvider connects to the code host the review payload came from.
+// Self-hosted instances are reached at the base URL registered with the repository.
+func newSCMProvider(ctx context.Context, payload ReviewPayload) (service.SCMProvider, error) {
+	switch payload.Provider {
+	case "", service.ProviderGitHub:
+		return service.NewGitHubServiceFor(ctx, payload.RepoOwner, payload.RepoName, payload.InstallationID)
+	case service.ProviderGitLab:
+		baseURL, err := repositoryBaseURL(ctx, payload)
+		if err != nil {
+			return nil, err
+		}
+		return service.NewGitLabService(baseURL), nil
+	case service.ProviderGitea:
+		baseURL, err := repositoryBaseURL(ctx, payload)
+		if err != nil {
+			return nil, err
+		}
+		if baseURL == "" {
+			return nil, fmt.Errorf("no base URL registered for gitea repository %s/%s", payload.RepoOwner, payload.RepoName)
+		}
+		return service.NewGiteaService(baseURL), nil
+	}
+	return nil, fmt.Errorf("unknown code host %q", payload.Provider)
+}
+
+// repositoryBaseURL is the instance the webhook handler matched the
+// repository to, looked up again for tasks queued before it was recorded
+func repositoryBaseURL(ctx context.Context, payload ReviewPayload) (string, error) {
+	if payload.BaseURL != "" {
+		return payload.BaseURL, nil
+	}
+	repo, err := repository.NewRepoRepository(database.Pool).FindRepository(ctx, payload.Provider, payload.RepoOwner, payload.RepoName, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to load repository: %w", err)
+	}
+	if repo == nil {
+		return "", nil
+	}
+	return repo.BaseURL, nil
+}
+
+// installationSuspended reports whether a task was queued for a GitHub App
+// installation that has been suspended since; its token exchange would fail
+func installationSuspended(ctx context.Context, installationID int64) bool {
+	if installationID == 0 {
+		return false
+	}
+	suspended, err := repository.NewInstallationRepository(database.Pool).IsSuspended(ctx, installationID)
+	if err != nil {
+		log.Printf(" %v", err)
+		return false
+	}
+	if suspended {
+		log.Printf(" Skipping: installation %d is suspended", installationID)
+	}
+	return suspended
+}
@@ -9,19 +9,13 @@ import (
 
 	"github.com/hibiken/asynq"
 	
+	"github.com/DHRUVV23/ai-code-review/backend/internal/database"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/model"
+	"github.com/DHRUVV23/ai-code-review/backend/internal/repository"
 	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
 )
 
 
-type ReviewIssue struct {
-	File       string `json:"file"`
-	Line       int    `json:"line"`
-	Type       string `json:"type"`
-	Severity   string `json:"severity"`
-	Message    string `json:"message"`
-	Suggestion string `json:"suggestion"`
-}
-
 func HandleReviewTask(ctx context.Context, t *asynq.Task) error {
 	var payload ReviewPayload
 	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
@@ -30,22 +24,33 @@ func HandleReviewTask(ctx context.Context, t *asynq.Task) error {
 
 	log.Printf("Processing Review for: %s/%s PR #%d", payload.RepoOwner, payload.RepoName, payload.PRNumber)
 
-	ghService := service.NewGitHubService() 
+	if installationSuspended(ctx, payload.InstallationID) {
+		return nil
+	}
+
+	scm, err := newSCMProvider(ctx, payload)
+	if err != nil {
+		return err
+	}
+	repoConfig := loadRepoConfig(ctx, payload.RepoOwner, payload.RepoName)
+
+	if payload.Draft && !repoConfig.ReviewDrafts && !payload.Force {
+		log.Printf(" Skipping: PR #%d is a draft", payload.PRNumber)
+		return nil
+	}
 	
-	alreadyCommented, err := ghService.HasBotCommented(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber)
+	alreadyCommented, err := scm.HasBotCommented(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber)
 	if err != nil {
 		log.Printf("Failed to check existing comments: %v", err)
 		// We continue anyway to be safe, or you could return err to retry
 	}
 	
-	if alreadyCommented {
+	if alreadyCommented && !payload.Force {
 		log.Printf(" Skipping: Bot already reviewed PR #%d", payload.PRNumber)
 		return nil 
 	}
 
-	aiService := service.NewAIService()
-
-	diff, err := ghService.GetPullRequestDiff(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber)
+	diff, err := scm.GetPullRequestDiff(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber)
 	if err != nil {
 		log.Printf(" Failed to get diff: %v", err)
 		return err
@@ -56,42 +61,269 @@ func HandleReviewTask(ctx context.Context, t *asynq.Task) error {
 		return nil
 	}
 
-	reviewJSON, err := aiService.ReviewCode(ctx, diff, "concise")
+	target := reviewTarget{
+		Owner:   payload.RepoOwner,
+		Name:    payload.RepoName,
+		HeadSHA: payload.HeadSHA,
+		Label:   fmt.Sprintf("PR #%d", payload.PRNumber),
+		PR:      &payload,
+	}
+	outcome, err := runReviewPipeline(ctx, scm, target, repoConfig, diff)
 	if err != nil {
-		log.Printf("❌ AI Analysis failed: %v", err)
 		return err
 	}
+	return publishReview(ctx, scm, payload, diff, outcome.Issues, outcome.Body)
+}
 
+// reviewWithAI asks the AI for findings. An empty answer to a suspicious
+// diff (a large one that talks to the model) is the injection working, so
+// it asks again with the stricter prompt.
+func reviewWithAI(ctx context.Context, aiService *service.AIService, input service.ReviewInput, suspicious bool) (reviewJSON string, issues []service.ReviewIssue, parseErr, err error) {
+	reviewJSON, err = aiService.ReviewCode(ctx, input)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	issues, parseErr = service.ParseReviewIssues(reviewJSON)
 
+	if parseErr == nil && len(issues) == 0 && suspicious {
+		log.Println(" Empty review despite injection markers, retrying in strict mode")
+		input.Strict = true
+		if strictJSON, err := aiService.ReviewCode(ctx, input); err != nil {
+			log.Printf(" Strict review failed: %v", err)
+		} else if strictIssues, err := service.ParseReviewIssues(strictJSON); err == nil {
+			reviewJSON, issues = strictJSON, strictIssues
+		}
+	}
+	return reviewJSON, issues, parseErr, nil
+}
 
-	commentBody := formatReviewToMarkdown(reviewJSON)
-
-	alreadyCommentedAgain, _ := ghService.HasBotCommented(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber)
-    if alreadyCommentedAgain {
+// publishReview posts the summary table, then the inline findings
+func publishReview(ctx context.Context, scm service.SCMProvider, payload ReviewPayload, diff string, issues []service.ReviewIssue, commentBody string) error {
+	alreadyCommentedAgain, _ := scm.HasBotCommented(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber)
+    if alreadyCommentedAgain && !payload.Force {
         log.Printf(" Race Condition Avoided: Comment already exists for PR #%d", payload.PRNumber)
         return nil
     }
 	
-	if err := ghService.PostComment(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber, commentBody); err != nil {
+	if err := scm.PostComment(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber, commentBody); err != nil {
 		log.Printf(" Failed to post comment: %v", err)
 		return err
 	}
 
 	log.Printf("Review Posted for PR #%d!", payload.PRNumber)
+
+	if len(issues) > 0 {
+		postInlineFindings(ctx, scm, payload, diff, issues)
+	}
 	return nil
 }
 
-func formatReviewToMarkdown(rawJSON string) string {
-	cleanJSON := strings.TrimSpace(rawJSON)
-	cleanJSON = strings.TrimPrefix(cleanJSON, "```json")
-	cleanJSON = strings.TrimPrefix(cleanJSON, "```")
-	cleanJSON = strings.TrimSuffix(cleanJSON, "```")
+// scanForSecrets checks every added line, including files never sent to the
+// AI such as .env, and reports the result as a status check when the
+// repository uses the scan as a hard gate.
+func scanForSecrets(ctx context.Context, scm service.SCMProvider, target reviewTarget, cfg *model.Configuration, scanner *service.SecretScanner, diff string) []service.ReviewIssue {
+	issues := service.SecretIssues(scanner.Scan(service.NewDiffParser().ParseAll(diff)))
+
+	if cfg.SecretScanBlocking && target.HeadSHA != "" {
+		state, description := "success", "No secrets found in the added lines"
+		if len(issues) > 0 {
+			state, description = "failure", fmt.Sprintf("%d possible secret(s) found in the added lines", len(issues))
+		}
+		if err := scm.SetCommitStatus(ctx, target.Owner, target.Name, target.HeadSHA, state, "ai-code-review/secrets", description); err != nil {
+			log.Printf(" Failed to report secret scan status: %v", err)
+		}
+	}
+	return issues
+}
+
+func newSecretScanner(cfg *model.Configuration) *service.SecretScanner {
+	scanner, err := service.NewSecretScanner(cfg.SecretPatterns, cfg.SecretAllowlist)
+	if err != nil {
+		log.Printf(" Invalid secret scan settings, using defaults: %v", err)
+		scanner, _ = service.NewSecretScanner("", "")
+	}
+	return scanner
+}
+
+func newRedactor(cfg *model.Configuration, scanner *service.SecretScanner) *service.Redactor {
+	redactor, err := service.NewRedactor(scanner, cfg.SensitivePatterns)
+	if err != nil {
+		log.Printf(" Invalid sensitive patterns, redacting secrets and emails only: %v", err)
+		redactor, _ = service.NewRedactor(scanner, "")
+	}
+	return redactor
+}
+
+// loadRepoConfig returns the stored configuration for a repository, or the
+// defaults when the repository was never registered through the dashboard.
+func loadRepoConfig(ctx context.Context, owner, name string) *model.Configuration {
+	defaults := &model.Configuration{ReviewStyle: "concise", SummaryMode: "comment"}
+
+	repo, err := repository.NewRepoRepository(database.Pool).GetRepositoryByOwnerName(ctx, owner, name)
+	if err != nil || repo == nil {
+		return defaults
+	}
+
+	cfg, err := repository.NewConfigRepository(database.Pool).GetByRepoID(ctx, repo.ID)
+	if err != nil || cfg == nil {
+		return defaults
+	}
+	return cfg
+}
+
+// postInlineFindings pins every finding that lands on a line of the diff as an
+// inline review comment, then stores all findings so that replies in those
+// threads can be traced back to them. Failures here never fail the task: the
+// summary table has already been posted.
+func postInlineFindings(ctx context.Context, scm service.SCMProvider, payload ReviewPayload, diff string, issues []service.ReviewIssue) {
+	files := make(map[string]service.FileChange)
+	for _, f := range service.NewDiffParser().ParseAll(diff) {
+		files[f.Path] = f
+	}
+
+	var drafts []service.InlineComment
+	for i, issue := range issues {
+		file, ok := files[issue.File]
+		if !ok {
+			continue
+		}
+
+		// A suggestion is only offered when its whole range is in one hunk,
+		// otherwise GitHub would reject it or apply it to the wrong lines.
+		if issue.HasSuggestedCode() && !file.HunkContains(issue.StartLine, issue.EndLine) {
+			log.Printf(" Dropping suggested code for %s:%d-%d: range is not in the diff", issue.File, issue.StartLine, issue.EndLine)
+			issues[i].StartLine, issues[i].EndLine, issues[i].SuggestedCode = 0, 0, ""
+			issue = issues[i]
+		}
+
+		if issue.HasSuggestedCode() {
+			drafts = append(drafts, service.InlineComment{
+				Path:      issue.File,
+				OldPath:   file.OldPath,
+				Line:      issue.EndLine,
+				StartLine: issue.StartLine,
+				Body:      formatInlineComment(issue),
+			})
+			continue
+		}
+
+		if !file.HasLine(issue.Line) {
+			continue
+		}
+		drafts = append(drafts, service.InlineComment{
+			Path:    issue.File,
+			OldPath: file.OldPath,
+			Line:    issue.Line,
+			Body:    formatInlineComment(issue),
+		})
+	}
+
+	var created []service.PostedComment
+	if len(drafts) > 0 && payload.HeadSHA != "" {
+		body := "🤖 Inline findings from the AI Code Review. Reply to a comment to ask a question or to tell me it is a false positive.\n\n" + service.BotCommentMarker
+		var err error
+		created, err = scm.PostInlineComments(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber, payload.HeadSHA, body, drafts)
+		if err != nil {
+			log.Printf(" Failed to post inline comments: %v", err)
+		}
+	}
+
+	repo, err := repository.NewRepoRepository(database.Pool).GetRepositoryByOwnerName(ctx, payload.RepoOwner, payload.RepoName)
+	if err != nil || repo == nil {
+		log.Printf(" Repository %s/%s is not registered, findings will not be stored", payload.RepoOwner, payload.RepoName)
+		return
+	}
+
+	reviewRepo := repository.NewReviewRepository(database.Pool)
+	reviewID, err := reviewRepo.CreateReview(ctx, repo.ID, payload.PRNumber, payload.HeadSHA)
+	if err != nil {
+		log.Printf(" Failed to store review: %v", err)
+		return
+	}
+
+	issueRepo := repository.NewIssueRepository(database.Pool)
+	used := make(map[int64]bool)
+	for _, issue := range issues {
+		record := &model.ReviewIssue{
+			ReviewID:      reviewID,
+			FilePath:      issue.File,
+			LineNumber:    issue.Line,
+			Severity:      issue.Severity,
+			Category:      issue.Type,
+			Message:       issue.Message,
+			Suggestion:    issue.Suggestion,
+			StartLine:     issue.StartLine,
+			EndLine:       issue.EndLine,
+			SuggestedCode: issue.SuggestedCode,
+			Source:        issue.Source,
+		}
+		line := issue.Line
+		if issue.HasSuggestedCode() {
+			line = issue.EndLine
+		}
+		for _, c := range created {
+			if !used[c.ID] && c.Path == issue.File && c.Line == line {
+				record.GithubCommentID = c.ID
+				used[c.ID] = true
+				break
+			}
+		}
+		if err := issueRepo.CreateIssue(ctx, record); err != nil {
+			log.Printf(" Failed to store finding: %v", err)
+		}
+	}
+
+	content, _ := json.Marshal(issues)
+	if err := reviewRepo.UpdateReviewResult(ctx, reviewID, string(content)); err != nil {
+		log.Printf(" Failed to complete review record: %v", err)
+	}
+}
+
+func formatInlineComment(issue service.ReviewIssue) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s **%s** · **%s**%s: %s\n", severityIcon(issue.Severity), issue.Severity, issue.Type, sourceTag(issue), issue.Message)
+	if issue.Suggestion != "" {
+		fmt.Fprintf(&sb, "\n💡 %s\n", issue.Suggestion)
+	}
+	if issue.HasSuggestedCode() {
+		// Use a fence longer than any backtick run inside the replacement
+		fence := "```"
+		for strings.Contains(issue.SuggestedCode, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(&sb, "\n%ssuggestion\n%s\n%s\n", fence, strings.TrimRight(issue.SuggestedCode, "\n"), fence)
+	}
+	sb.WriteString("\n<sub>Reply to this comment to discuss the finding.</sub>\n")
+	sb.WriteString(service.BotCommentMarker)
+	return sb.String()
+}
+
+// sourceTag marks findings that came from the deterministic analyzers
+func sourceTag(issue service.ReviewIssue) string {
+	switch issue.Source {
+	case service.SourceStatic:
+		return " `static`"
+	case service.SourceSecretScan:
+		return " `secret-scan`"
+	case service.SourcePromptGuard:
+		return " `prompt-guard`"
+	}
+	return ""
+}
 
-	var issues []ReviewIssue
-	if err := json.Unmarshal([]byte(cleanJSON), &issues); err != nil {
-		return fmt.Sprintf("## 🤖 AI Review\n\n%s", rawJSON)
+func severityIcon(severity string) string {
+	switch strings.ToLower(severity) {
+	case "high":
+		return "🔴"
+	case "medium":
+		return "🟠"
+	case "low":
+		return "🟢"
 	}
+	return "⚪"
+}
 
+func formatReviewToMarkdown(issues []service.ReviewIssue) string {
 	if len(issues) == 0 {
 		return "##  AI Code Review\n\n **LGTM! (Looks Good To Me)**\n\nNo critical issues found. Great job!"
 	}
@@ -102,20 +334,12 @@ func formatReviewToMarkdown(rawJSON string) string {
 	sb.WriteString("| :--- | :--- | :--- | :--- | :--- |\n")
 
 	for _, issue := range issues {
-		
-		severityIcon := "⚪"
-		switch strings.ToLower(issue.Severity) {
-		case "high":
-			severityIcon = "🔴"
-		case "medium":
-			severityIcon = "🟠"
-		case "low":
-			severityIcon = "🟢"
+		message := tableCell(issue.Message)
+		if len(issue.Mentions) > 0 {
+			message += "<br>cc " + strings.Join(issue.Mentions, " ")
 		}
-
-		
-		row := fmt.Sprintf("| %s **%s** | `%s` | %d | **%s**: %s | %s |\n",
-			severityIcon, issue.Severity, issue.File, issue.Line, issue.Type, issue.Message, issue.Suggestion)
+		row := fmt.Sprintf("| %s **%s** | `%s` | %d | **%s**%s: %s | %s |\n",
+			severityIcon(issue.Severity), issue.Severity, issue.File, issue.Line, issue.Type, sourceTag(issue), message, tableCell(issue.Suggestion))
 		sb.WriteString(row)
 	}
 
@@ -123,6 +347,33 @@ func formatReviewToMarkdown(rawJSON string) string {
 	return sb.String()
 }
 
+func formatSkippedFiles(skipped []service.SkippedFile) string {
+	if len(skipped) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n### ⏭️ Skipped Generated Files\n\n")
+	for _, f := range skipped {
+		fmt.Fprintf(&sb, "- `%s`: %s\n", f.Path, f.Reason)
+	}
+	return sb.String()
+}
+
+func formatOmittedFiles(omitted []service.SkippedFile) string {
+	if len(omitted) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n### ✂️ Not Reviewed by the AI\n\n")
+	sb.WriteString("This PR is larger than the review budget, so the highest-priority files were reviewed first. These were left out:\n\n")
+	for _, f := range omitted {
+		fmt.Fprintf(&sb, "- `%s`: %s\n", f.Path, f.Reason)
+	}
+	return sb.String()
+}
+
 func StartWorker(redisAddr string) {
 	srv := asynq.NewServer(
 		asynq.RedisClientOpt{Addr: redisAddr},
@@ -131,9 +382,18 @@ func StartWorker(redisAddr string) {
 		},
 	)
 
+	// Tasks queue follow-up work, such as autofix asking for a new review
+	if Client == nil {
+		Client = asynq.NewClient(asynq.RedisClientOpt{Addr: redisAddr})
+	}
+
 	mux := asynq.NewServeMux()
 	
 	mux.HandleFunc(TypeReviewPR, HandleReviewTask)
+	mux.HandleFunc(TypeReplyComment, HandleReplyTask)
+	mux.HandleFunc(TypeAutofixPR, HandleAutofixTask)
+	mux.HandleFunc(TypePRMerged, HandleMergedTask)
+	mux.HandleFunc(TypeReviewPush, HandlePushReviewTask)
 
 	
 	go func() {
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/DHRUVV23/ai-code-review/backend/internal/service"
+)
+
+const (
+	summaryStartMarker   = "<!-- ai-summary:start -->"
+	summaryEndMarker     = "<!-- ai-summary:end -->"
+	summaryCommentMarker = "<!-- ai-summary:comment -->"
+)
+
+// postPRSummary writes the walkthrough into the PR description or a separate
+// comment, depending on the repository's summary_mode. Failures are logged
+// and never block the review itself.
+func postPRSummary(ctx context.Context, scm service.SCMProvider, aiService *service.AIService, payload ReviewPayload, mode string, files []service.FileChange, redactor *service.Redactor) {
+	if mode == "off" || len(files) == 0 {
+		return
+	}
+
+	summary, err := aiService.SummarizePR(ctx, redactor.RedactFiles(files))
+	if err != nil {
+		log.Printf(" Failed to generate PR summary: %v", err)
+		return
+	}
+	section := redactor.Restore(formatSummaryToMarkdown(summary))
+
+	// Only GitHub descriptions are edited; other hosts get the comment
+	ghService, onGitHub := scm.(*service.GitHubService)
+	switch {
+	case mode == "description" && onGitHub:
+		err = ghService.UpdateDescriptionSection(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber, summaryStartMarker, summaryEndMarker, section)
+	default:
+		err = scm.UpsertComment(ctx, payload.RepoOwner, payload.RepoName, payload.PRNumber, summaryCommentMarker, section+"\n\n"+summaryCommentMarker)
+	}
+	if err != nil {
+		log.Printf(" Failed to post PR summary: %v", err)
+		return
+	}
+	log.Printf("PR Summary Posted for PR #%d (%s)", payload.PRNumber, mode)
+}
+
+func formatSummaryToMarkdown(summary *service.PRSummary) string {
+	var sb strings.Builder
+	sb.WriteString("## 📋 AI PR Summary\n\n")
+	sb.WriteString(strings.TrimSpace(summary.Description))
+	sb.WriteString("\n\n")
+
+	if len(summary.Files) > 0 {
+		sb.WriteString("### 📂 Walkthrough\n\n")
+		sb.WriteString("| File | Summary |\n")
+		sb.WriteString("| :--- | :--- |\n")
+		for _, f := range summary.Files {
+			fmt.Fprintf(&sb, "| `%s` | %s |\n", f.Path, tableCell(f.Summary))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(summary.RiskAreas) > 0 {
+		sb.WriteString("### ⚠️ Risk Areas\n\n")
+		for _, risk := range summary.RiskAreas {
+			fmt.Fprintf(&sb, "- %s\n", risk)
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(summary.TestingChecklist) > 0 {
+		sb.WriteString("### ✅ Suggested Testing\n\n")
+		for _, item := range summary.TestingChecklist {
+			fmt.Fprintf(&sb, "- [ ] %s\n", item)
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("---\n*generated by AI Code Reviewer*")
+	return sb.String()
+}
+
+// tableCell keeps free text from breaking a Markdown table row
+func tableCell(text string) string {
+	text = strings.ReplaceAll(text, "\n", " ")
+	return strings.ReplaceAll(text, "|", "\\|")
+}
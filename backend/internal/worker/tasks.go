@@ -2,30 +2,121 @@ package worker
 
 import (
 	"encoding/json"
+	"time"
+
 	"github.com/hibiken/asynq"
 )
 
 // Task Name
-const TypeReviewPR = "review:pr"
+const (
+	TypeReviewPR     = "review:pr"
+	TypeReplyComment = "review:reply"
+	TypeAutofixPR    = "autofix:pr"
+	TypePRMerged     = "pr:merged"
+	TypeReviewPush   = "review:push"
+)
 
 // Payload
 type ReviewPayload struct {
-	RepoName  string `json:"repo_name"`
-	RepoOwner string `json:"repo_owner"`
-	PRNumber  int    `json:"pr_number"`
-	RepoID    int64  `json:"repo_id"`
+	RepoName       string `json:"repo_name"`
+	RepoOwner      string `json:"repo_owner"`
+	PRNumber       int    `json:"pr_number"`
+	RepoID         int64  `json:"repo_id"`
+	HeadSHA        string `json:"head_sha"`
+	BaseSHA        string `json:"base_sha"`
+	Draft          bool   `json:"draft"`
+	Force          bool   `json:"force"`                     // Requested with the ai-review label: review even if already reviewed or draft
+	InstallationID int64  `json:"installation_id,omitempty"` // GitHub App installation, 0 when unknown
+	Provider       string `json:"provider,omitempty"`        // Code host, GitHub when empty
+	BaseURL        string `json:"base_url,omitempty"`        // Self-hosted instance the repository was registered on
+}
+
+// ReplyPayload describes a developer's reply under one of our inline comments
+type ReplyPayload struct {
+	RepoName       string `json:"repo_name"`
+	RepoOwner      string `json:"repo_owner"`
+	PRNumber       int    `json:"pr_number"`
+	CommentID      int64  `json:"comment_id"`
+	InReplyTo      int64  `json:"in_reply_to"`
+	Author         string `json:"author"`
+	Body           string `json:"body"`
+	InstallationID int64  `json:"installation_id,omitempty"` // GitHub App installation, 0 when unknown
+}
+
+// AutofixPayload asks for the accepted suggestions of a PR to be applied
+type AutofixPayload struct {
+	RepoName       string `json:"repo_name"`
+	RepoOwner      string `json:"repo_owner"`
+	PRNumber       int    `json:"pr_number"`
+	RequestedBy    string `json:"requested_by"`
+	InstallationID int64  `json:"installation_id,omitempty"` // GitHub App installation, 0 when unknown
 }
 
 // NewReviewTask creates the task (Use this name!)
-func NewReviewTask(repoName, repoOwner string, prNumber int, repoID int64) (*asynq.Task, error) {
-	payload, err := json.Marshal(ReviewPayload{
-		RepoName:  repoName,
-		RepoOwner: repoOwner,
-		PRNumber:  prNumber,
-		RepoID:    repoID,
-	})
+func NewReviewTask(p ReviewPayload) (*asynq.Task, error) {
+	payload, err := json.Marshal(p)
 	if err != nil {
 		return nil, err
 	}
 	return asynq.NewTask(TypeReviewPR, payload), nil
-}
\ No newline at end of file
+}
+
+// MergedPayload is the final state of a merged PR
+type MergedPayload struct {
+	RepoName       string    `json:"repo_name"`
+	RepoOwner      string    `json:"repo_owner"`
+	PRNumber       int       `json:"pr_number"`
+	MergedAt       time.Time `json:"merged_at"`
+	MergeCommitSHA string    `json:"merge_commit_sha"`
+	HeadSHA        string    `json:"head_sha"`
+	Additions      int       `json:"additions"`
+	Deletions      int       `json:"deletions"`
+	ChangedFiles   int       `json:"changed_files"`
+}
+
+// NewReplyTask creates the task that answers a reply in a review thread
+func NewReplyTask(p ReplyPayload) (*asynq.Task, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeReplyComment, payload), nil
+}
+
+// NewAutofixTask creates the task that turns suggested code into a follow-up PR
+func NewAutofixTask(p AutofixPayload) (*asynq.Task, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeAutofixPR, payload), nil
+}
+
+// NewMergedTask creates the task that records a merged PR for analytics
+func NewMergedTask(p MergedPayload) (*asynq.Task, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypePRMerged, payload), nil
+}
+
+// PushPayload is a range of commits pushed straight to a branch
+type PushPayload struct {
+	RepoName       string `json:"repo_name"`
+	RepoOwner      string `json:"repo_owner"`
+	Branch         string `json:"branch"`
+	BeforeSHA      string `json:"before_sha"`
+	HeadSHA        string `json:"head_sha"`
+	Pusher         string `json:"pusher"`
+	InstallationID int64  `json:"installation_id,omitempty"` // GitHub App installation, 0 when unknown
+}
+
+// NewPushReviewTask creates the task that reviews a pushed commit range
+func NewPushReviewTask(p PushPayload) (*asynq.Task, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeReviewPush, payload), nil
+}